@@ -42,6 +42,8 @@ func main() {
 
 	id := fmt.Sprintf("%s%sApplicationStack", strings.Title(applicationProps.Tenant), strings.Title(applicationProps.Environment))
 
+	// resources the permissions boundary would not cover are synthesis
+	// errors, which cdk refuses to deploy
 	stacks.ApplicationStack(app, id, &applicationProps)
 
 	app.Synth(nil)
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"permission-boundary-pipeline-cdk/pkg/bootstrap"
+)
+
+// exit codes, a security relevant change fails the build
+const (
+	exitSecurity = 1
+	exitUsage    = 2
+)
+
+func main() {
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bootstrapdiff old-template new-template\n\n"+
+			"Compares two CDK bootstrap templates, e.g. the vendored template and\n"+
+			"cdk bootstrap --show-template output (- reads stdin). Exits %d when a\n"+
+			"change touches policies, trust or the pipeline trust overrides.\n", exitSecurity)
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
+	log.SetFlags(0)
+
+	old, err := bootstrap.Load(flag.Arg(0))
+	if err != nil {
+		log.Printf("Cannot load template: %s", err)
+		os.Exit(exitUsage)
+	}
+
+	new, err := bootstrap.Load(flag.Arg(1))
+	if err != nil {
+		log.Printf("Cannot load template: %s", err)
+		os.Exit(exitUsage)
+	}
+
+	report := bootstrap.Diff(old, new, bootstrap.TrustOverrides)
+	report.Write(os.Stdout)
+
+	if report.Security() {
+		log.Print("Security relevant bootstrap changes, review them and the pipeline trust overrides before updating the vendored template")
+		os.Exit(exitSecurity)
+	}
+}
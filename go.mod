@@ -3,8 +3,12 @@ module permission-boundary-pipeline-cdk
 go 1.16
 
 require (
+	api v0.0.0
 	github.com/aws/aws-cdk-go/awscdk v1.128.0-devpreview
 	github.com/aws/constructs-go/constructs/v3 v3.3.161
 	github.com/aws/jsii-runtime-go v1.39.0
 	github.com/kelseyhightower/envconfig v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+replace api => ./resources/api
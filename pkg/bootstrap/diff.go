@@ -0,0 +1,366 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Kind groups changes in the report
+type Kind string
+
+const (
+	KindParameter Kind = "parameter"
+	KindResource  Kind = "resource"
+	KindStatement Kind = "statement"
+	KindTrust     Kind = "trust"
+	KindOverride  Kind = "override"
+)
+
+// Change is one difference between two templates
+type Change struct {
+	Kind Kind
+	// where the change is e.g. LookupRole.AssumeRolePolicyDocument[1]
+	Path   string
+	Detail string
+	// changes permissions or who can assume a role
+	Security bool
+}
+
+// Report is the drift between two bootstrap templates
+type Report struct {
+	OldVersion string
+	NewVersion string
+	Changes    []Change
+}
+
+// Security reports whether any change is security relevant
+func (r *Report) Security() bool {
+	for _, c := range r.Changes {
+		if c.Security {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints the report, security relevant changes are marked with !
+func (r *Report) Write(w io.Writer) {
+
+	fmt.Fprintf(w, "bootstrap version %s -> %s\n", orNone(r.OldVersion), orNone(r.NewVersion))
+
+	if len(r.Changes) == 0 {
+		fmt.Fprintln(w, "no changes")
+		return
+	}
+
+	for _, c := range r.Changes {
+		mark := " "
+		if c.Security {
+			mark = "!"
+		}
+		fmt.Fprintf(w, "%s %-9s %s: %s\n", mark, c.Kind, c.Path, c.Detail)
+	}
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// resource types whose properties control access
+var securityTypes = map[string]bool{
+	"AWS::IAM::Role":          true,
+	"AWS::IAM::Policy":        true,
+	"AWS::IAM::ManagedPolicy": true,
+	"AWS::S3::Bucket":         true,
+	"AWS::S3::BucketPolicy":   true,
+	"AWS::KMS::Key":           true,
+	"AWS::ECR::Repository":    true,
+}
+
+// policy properties by resource type, compared statement by statement
+var policyProperties = map[string][]string{
+	"AWS::IAM::Role":          {"AssumeRolePolicyDocument", "Policies"},
+	"AWS::IAM::Policy":        {"PolicyDocument"},
+	"AWS::IAM::ManagedPolicy": {"PolicyDocument"},
+	"AWS::S3::BucketPolicy":   {"PolicyDocument"},
+	"AWS::KMS::Key":           {"KeyPolicy"},
+	"AWS::ECR::Repository":    {"RepositoryPolicyText"},
+}
+
+// Diff compares two templates and checks the overrides still apply to the
+// new one
+func Diff(old, new *Template, overrides []TrustOverride) *Report {
+
+	report := &Report{OldVersion: old.Version(), NewVersion: new.Version()}
+
+	report.parameters(old, new)
+	report.resources(old, new)
+
+	for _, o := range overrides {
+		if err := o.Check(new); err != nil {
+			report.add(Change{Kind: KindOverride, Path: o.Resource, Detail: err.Error(), Security: true})
+		}
+	}
+
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		return report.Changes[i].Path < report.Changes[j].Path
+	})
+
+	return report
+}
+
+func (r *Report) add(c Change) {
+	r.Changes = append(r.Changes, c)
+}
+
+func (r *Report) parameters(old, new *Template) {
+
+	for _, name := range keys(old.Parameters, new.Parameters) {
+
+		o, inOld := old.Parameters[name]
+		n, inNew := new.Parameters[name]
+
+		switch {
+		case !inNew:
+			r.add(Change{Kind: KindParameter, Path: name, Detail: "removed"})
+		case !inOld:
+			r.add(Change{Kind: KindParameter, Path: name, Detail: "added " + compact(n)})
+		case !reflect.DeepEqual(o, n):
+			r.add(Change{Kind: KindParameter, Path: name, Detail: fmt.Sprintf("changed %s -> %s", compact(o), compact(n))})
+		}
+	}
+}
+
+func (r *Report) resources(old, new *Template) {
+
+	for _, id := range resourceKeys(old.Resources, new.Resources) {
+
+		o, inOld := old.Resources[id]
+		n, inNew := new.Resources[id]
+
+		switch {
+		case !inNew:
+			r.add(Change{Kind: KindResource, Path: id, Detail: "removed " + o.Type, Security: securityTypes[o.Type]})
+			continue
+		case !inOld:
+			r.add(Change{Kind: KindResource, Path: id, Detail: "added " + n.Type, Security: securityTypes[n.Type]})
+			continue
+		case o.Type != n.Type:
+			r.add(Change{Kind: KindResource, Path: id, Detail: fmt.Sprintf("type changed %s -> %s", o.Type, n.Type), Security: true})
+			continue
+		}
+
+		policies := map[string]bool{}
+		for _, property := range policyProperties[n.Type] {
+			policies[property] = true
+		}
+
+		for _, property := range keys(o.Properties, n.Properties) {
+
+			ov, nv := o.Properties[property], n.Properties[property]
+
+			if reflect.DeepEqual(ov, nv) {
+				continue
+			}
+
+			path := id + "." + property
+
+			switch {
+			case property == "Policies":
+				r.inlinePolicies(path, ov, nv)
+			case policies[property]:
+				kind := KindStatement
+				if property == "AssumeRolePolicyDocument" {
+					kind = KindTrust
+				}
+				r.statements(kind, path, ov, nv)
+			default:
+				r.add(Change{Kind: KindResource, Path: path, Detail: fmt.Sprintf("changed %s -> %s", compact(ov), compact(nv)), Security: securityTypes[n.Type]})
+			}
+		}
+	}
+}
+
+// inlinePolicies compares role inline policies by name
+func (r *Report) inlinePolicies(path string, old, new interface{}) {
+
+	byName := func(v interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		list, _ := v.([]interface{})
+		for i, p := range list {
+			policy, _ := p.(map[string]interface{})
+			name, ok := policy["PolicyName"].(string)
+			if !ok {
+				name = fmt.Sprintf("#%d", i)
+			}
+			m[name] = policy["PolicyDocument"]
+		}
+		return m
+	}
+
+	o, n := byName(old), byName(new)
+
+	for _, name := range keys(o, n) {
+		if !reflect.DeepEqual(o[name], n[name]) {
+			r.statements(KindStatement, path+"."+name, o[name], n[name])
+		}
+	}
+}
+
+// statement is a policy statement with the key it is matched by
+type statement struct {
+	key   string
+	index int
+	value interface{}
+}
+
+// statements compares two policy documents statement by statement,
+// statements are matched by Sid and otherwise by content so a statement
+// that only moved is reported as an index shift
+func (r *Report) statements(kind Kind, path string, old, new interface{}) {
+
+	o, n := policyStatements(old), policyStatements(new)
+
+	matched := map[int]bool{}
+
+	for _, before := range o {
+
+		after, found := match(before, n, matched)
+		if !found {
+			r.add(Change{Kind: kind, Path: fmt.Sprintf("%s[%d]", path, before.index), Detail: "removed " + compact(before.value), Security: true})
+			continue
+		}
+
+		matched[after.index] = true
+
+		if !reflect.DeepEqual(before.value, after.value) {
+			r.add(Change{Kind: kind, Path: fmt.Sprintf("%s[%d]", path, after.index), Detail: fmt.Sprintf("changed %s -> %s", compact(before.value), compact(after.value)), Security: true})
+		}
+
+		if before.index != after.index {
+			r.add(Change{Kind: kind, Path: fmt.Sprintf("%s[%d]", path, after.index), Detail: fmt.Sprintf("moved from index %d", before.index), Security: true})
+		}
+	}
+
+	for _, after := range n {
+		if !matched[after.index] {
+			r.add(Change{Kind: kind, Path: fmt.Sprintf("%s[%d]", path, after.index), Detail: "added " + compact(after.value), Security: true})
+		}
+	}
+}
+
+// match finds the new statement for an old one, by Sid, then an identical
+// statement, then one at the same index without a Sid
+func match(old statement, candidates []statement, matched map[int]bool) (statement, bool) {
+
+	if old.key != "" {
+		for _, c := range candidates {
+			if !matched[c.index] && c.key == old.key {
+				return c, true
+			}
+		}
+		return statement{}, false
+	}
+
+	for _, c := range candidates {
+		if !matched[c.index] && c.key == "" && reflect.DeepEqual(c.value, old.value) {
+			return c, true
+		}
+	}
+
+	for _, c := range candidates {
+		if !matched[c.index] && c.key == "" && c.index == old.index {
+			return c, true
+		}
+	}
+
+	return statement{}, false
+}
+
+// policyStatements lists the statements of a policy document, a single
+// statement may be given without a list
+func policyStatements(document interface{}) []statement {
+
+	d, _ := document.(map[string]interface{})
+
+	var list []interface{}
+	switch s := d["Statement"].(type) {
+	case []interface{}:
+		list = s
+	case nil:
+	default:
+		list = []interface{}{s}
+	}
+
+	statements := []statement{}
+	for i, s := range list {
+		statements = append(statements, statement{key: sid(s), index: i, value: s})
+	}
+
+	return statements
+}
+
+// sid returns a statement's Sid, looking inside Fn::If for conditional
+// statements
+func sid(s interface{}) string {
+
+	m, ok := s.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	if id, ok := m["Sid"].(string); ok {
+		return id
+	}
+
+	if branches, ok := m["Fn::If"].([]interface{}); ok && len(branches) == 3 {
+		if id := sid(branches[1]); id != "" {
+			return "if:" + id
+		}
+	}
+
+	return ""
+}
+
+// keys returns the sorted union of the keys of two maps
+func keys(a, b map[string]interface{}) []string {
+
+	set := map[string]bool{}
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	return sorted(set)
+}
+
+func resourceKeys(a, b map[string]Resource) []string {
+
+	set := map[string]bool{}
+	for k := range a {
+		set[k] = true
+	}
+	for k := range b {
+		set[k] = true
+	}
+
+	return sorted(set)
+}
+
+func sorted(set map[string]bool) []string {
+
+	list := make([]string, 0, len(set))
+	for k := range set {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+
+	return list
+}
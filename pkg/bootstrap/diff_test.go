@@ -0,0 +1,183 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+)
+
+// the vendored template the pipeline stack tests include
+const vendored = "../stacks/testdata/bootstrap-template.yaml"
+
+func load(t *testing.T) *Template {
+	t.Helper()
+
+	template, err := Load(vendored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return template
+}
+
+func findChange(report *Report, kind Kind, path string) (Change, bool) {
+	for _, c := range report.Changes {
+		if c.Kind == kind && strings.HasPrefix(c.Path, path) {
+			return c, true
+		}
+	}
+	return Change{}, false
+}
+
+func TestVendoredTemplateOverrides(t *testing.T) {
+
+	template := load(t)
+
+	for _, o := range TrustOverrides {
+		if err := o.Check(template); err != nil {
+			t.Error(err)
+		}
+	}
+
+	report := Diff(template, load(t), TrustOverrides)
+	if len(report.Changes) != 0 || report.Security() {
+		t.Errorf("identical templates differ: %+v", report.Changes)
+	}
+
+	if report.OldVersion != "8" {
+		t.Errorf("version = %q, want 8", report.OldVersion)
+	}
+}
+
+func TestShortFormIntrinsics(t *testing.T) {
+
+	short := `
+Resources:
+  Role:
+    Type: AWS::IAM::Role
+    Properties:
+      RoleName: !Sub cdk-${Qualifier}-role
+      Arn: !GetAtt Other.Arn
+      Account: !Ref AWS::AccountId
+      Statement: !If [HasTrust, {Effect: Allow}, !Ref AWS::NoValue]
+`
+
+	long := `{"Resources": {"Role": {"Type": "AWS::IAM::Role", "Properties": {
+  "RoleName": {"Fn::Sub": "cdk-${Qualifier}-role"},
+  "Arn": {"Fn::GetAtt": ["Other", "Arn"]},
+  "Account": {"Ref": "AWS::AccountId"},
+  "Statement": {"Fn::If": ["HasTrust", {"Effect": "Allow"}, {"Ref": "AWS::NoValue"}]}
+}}}}`
+
+	a, err := Parse(strings.NewReader(short))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Parse(strings.NewReader(long))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report := Diff(a, b, nil); len(report.Changes) != 0 {
+		t.Errorf("short and long form differ: %+v", report.Changes)
+	}
+}
+
+func TestParameterChange(t *testing.T) {
+
+	old, new := load(t), load(t)
+
+	new.Parameters["Qualifier"].(map[string]interface{})["Default"] = "changed"
+
+	report := Diff(old, new, TrustOverrides)
+
+	if _, ok := findChange(report, KindParameter, "Qualifier"); !ok {
+		t.Errorf("no parameter change: %+v", report.Changes)
+	}
+
+	if report.Security() {
+		t.Errorf("parameter default reported as security relevant: %+v", report.Changes)
+	}
+}
+
+func TestTrustStatementShift(t *testing.T) {
+
+	old, new := load(t), load(t)
+
+	// an upstream statement ahead of the account trust
+	document := new.Resources["LookupRole"].Properties["AssumeRolePolicyDocument"].(map[string]interface{})
+	document["Statement"] = append([]interface{}{
+		map[string]interface{}{
+			"Sid":       "NewTrust",
+			"Action":    "sts:AssumeRole",
+			"Effect":    "Allow",
+			"Principal": map[string]interface{}{"Service": "example.amazonaws.com"},
+		},
+	}, document["Statement"].([]interface{})...)
+
+	report := Diff(old, new, TrustOverrides)
+
+	if !report.Security() {
+		t.Fatal("trust change not security relevant")
+	}
+
+	if c, ok := findChange(report, KindTrust, "LookupRole.AssumeRolePolicyDocument[0]"); !ok || !strings.HasPrefix(c.Detail, "added") {
+		t.Errorf("added statement not reported: %+v", report.Changes)
+	}
+
+	if c, ok := findChange(report, KindTrust, "LookupRole.AssumeRolePolicyDocument[1]"); !ok || c.Detail != "moved from index 0" {
+		t.Errorf("index shift not reported: %+v", report.Changes)
+	}
+
+	if c, ok := findChange(report, KindOverride, "LookupRole"); !ok || !strings.Contains(c.Detail, "no longer the account trust") {
+		t.Errorf("broken override not reported: %+v", report.Changes)
+	}
+}
+
+func TestPolicyStatementChange(t *testing.T) {
+
+	old, new := load(t), load(t)
+
+	policies := new.Resources["DeploymentActionRole"].Properties["Policies"].([]interface{})
+	document := policies[0].(map[string]interface{})["PolicyDocument"].(map[string]interface{})
+	statements := document["Statement"].([]interface{})
+
+	cli := statements[4].(map[string]interface{})
+	if cli["Sid"] != "CliPermissions" {
+		t.Fatalf("unexpected statement %v", cli)
+	}
+	cli["Resource"] = []interface{}{"*", "arn:aws:iam::*:role/*"}
+
+	document["Statement"] = statements[:len(statements)-1]
+
+	report := Diff(old, new, TrustOverrides)
+
+	if c, ok := findChange(report, KindStatement, "DeploymentActionRole.Policies.default[4]"); !ok || !strings.HasPrefix(c.Detail, "changed") {
+		t.Errorf("changed statement not reported: %+v", report.Changes)
+	}
+
+	if c, ok := findChange(report, KindStatement, "DeploymentActionRole.Policies.default[6]"); !ok || !strings.HasPrefix(c.Detail, "removed") {
+		t.Errorf("removed statement not reported: %+v", report.Changes)
+	}
+
+	if _, ok := findChange(report, KindOverride, ""); ok {
+		t.Errorf("override reported for a policy change: %+v", report.Changes)
+	}
+}
+
+func TestRemovedRole(t *testing.T) {
+
+	old, new := load(t), load(t)
+
+	delete(new.Resources, "ImagePublishingRole")
+
+	report := Diff(old, new, TrustOverrides)
+
+	if c, ok := findChange(report, KindResource, "ImagePublishingRole"); !ok || !c.Security {
+		t.Errorf("removed role not reported: %+v", report.Changes)
+	}
+
+	if _, ok := findChange(report, KindOverride, "ImagePublishingRole"); !ok {
+		t.Errorf("override of a missing role not reported: %+v", report.Changes)
+	}
+}
@@ -0,0 +1,97 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TrustOverride replaces the account trust of a bootstrap role with the
+// pipeline. CloudFormation overrides address statements by index, so
+// Remove must be the account statement and Append the end of the list.
+type TrustOverride struct {
+	Resource string
+	Remove   int
+	Append   int
+}
+
+// TrustOverrides are applied by the pipeline stack to the CDK v1 bootstrap
+// template, version 8
+var TrustOverrides = []TrustOverride{
+	{Resource: "FilePublishingRole", Remove: 0, Append: 2},
+	{Resource: "ImagePublishingRole", Remove: 0, Append: 2},
+	{Resource: "DeploymentActionRole", Remove: 0, Append: 2},
+	{Resource: "LookupRole", Remove: 0, Append: 3},
+}
+
+// Check reports why the override would not apply as intended to a template,
+// nil when it does
+func (o TrustOverride) Check(t *Template) error {
+
+	resource, ok := t.Resources[o.Resource]
+	if !ok {
+		return fmt.Errorf("resource %s is missing", o.Resource)
+	}
+
+	if resource.Type != "AWS::IAM::Role" {
+		return fmt.Errorf("resource %s is a %s, not a role", o.Resource, resource.Type)
+	}
+
+	statements := trustStatements(resource)
+
+	if o.Remove >= len(statements) {
+		return fmt.Errorf("%s trust has %d statements, the override removes statement %d", o.Resource, len(statements), o.Remove)
+	}
+
+	if !accountTrust(statements[o.Remove]) {
+		return fmt.Errorf("%s trust statement %d is no longer the account trust, the override would remove %s", o.Resource, o.Remove, compact(statements[o.Remove]))
+	}
+
+	if o.Append != len(statements) {
+		if o.Append < len(statements) {
+			return fmt.Errorf("%s trust has %d statements, the override would replace statement %d", o.Resource, len(statements), o.Append)
+		}
+		return fmt.Errorf("%s trust has %d statements, the override appends at %d", o.Resource, len(statements), o.Append)
+	}
+
+	for i, statement := range statements {
+		if i != o.Remove && accountTrust(statement) {
+			return fmt.Errorf("%s trust statement %d also trusts the account and would be kept", o.Resource, i)
+		}
+	}
+
+	return nil
+}
+
+// trustStatements returns a role's trust policy statements
+func trustStatements(resource Resource) []interface{} {
+
+	document, _ := resource.Properties["AssumeRolePolicyDocument"].(map[string]interface{})
+	statements, _ := document["Statement"].([]interface{})
+
+	return statements
+}
+
+// accountTrust reports whether a statement trusts the whole account
+func accountTrust(statement interface{}) bool {
+
+	s, ok := statement.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	principal, _ := s["Principal"].(map[string]interface{})
+
+	return strings.Contains(compact(principal["AWS"]), `{"Ref":"AWS::AccountId"}`)
+}
+
+// compact renders a template fragment on one line
+func compact(v interface{}) string {
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,182 @@
+package bootstrap
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is the part of a CloudFormation template the diff looks at
+type Template struct {
+	Parameters map[string]interface{}
+	Resources  map[string]Resource
+}
+
+// Resource is a template resource, Properties in long form intrinsics
+type Resource struct {
+	Type       string
+	Properties map[string]interface{}
+}
+
+// Version returns the bootstrap version the template deploys, empty when
+// it has none
+func (t *Template) Version() string {
+
+	resource, ok := t.Resources["CdkBootstrapVersion"]
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprint(resource.Properties["Value"])
+}
+
+// Load reads a YAML or JSON template from a file, - reads stdin
+func Load(name string) (*Template, error) {
+
+	var (
+		raw []byte
+		err error
+	)
+
+	if name == "-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := Parse(strings.NewReader(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return template, nil
+}
+
+// Parse reads a YAML or JSON template, short form intrinsics e.g. !Sub are
+// expanded to their long form so both formats compare equal
+func Parse(r io.Reader) (*Template, error) {
+
+	var document yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	root, ok := value(&document).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template is not a mapping")
+	}
+
+	template := &Template{
+		Parameters: map[string]interface{}{},
+		Resources:  map[string]Resource{},
+	}
+
+	if parameters, ok := root["Parameters"].(map[string]interface{}); ok {
+		template.Parameters = parameters
+	}
+
+	resources, _ := root["Resources"].(map[string]interface{})
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("template has no resources")
+	}
+
+	for id, r := range resources {
+
+		resource, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource %s is not a mapping", id)
+		}
+
+		typ, _ := resource["Type"].(string)
+		properties, _ := resource["Properties"].(map[string]interface{})
+
+		template.Resources[id] = Resource{Type: typ, Properties: properties}
+	}
+
+	return template, nil
+}
+
+// value converts a YAML node to plain maps, slices and scalars
+func value(node *yaml.Node) interface{} {
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return value(node.Content[0])
+
+	case yaml.AliasNode:
+		return value(node.Alias)
+	}
+
+	if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+		return intrinsic(node)
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		m := map[string]interface{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m[node.Content[i].Value] = value(node.Content[i+1])
+		}
+		return m
+
+	case yaml.SequenceNode:
+		s := []interface{}{}
+		for _, item := range node.Content {
+			s = append(s, value(item))
+		}
+		return s
+	}
+
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return node.Value
+	}
+
+	// JSON and YAML templates mix integer and float numbers
+	if i, ok := v.(int); ok {
+		return float64(i)
+	}
+
+	return v
+}
+
+// intrinsic expands a short form function e.g. !GetAtt Role.Arn
+func intrinsic(node *yaml.Node) interface{} {
+
+	name := strings.TrimPrefix(node.Tag, "!")
+
+	plain := *node
+	plain.Tag = ""
+	if node.Kind == yaml.ScalarNode {
+		plain.Tag = "!!str"
+	}
+
+	arg := value(&plain)
+
+	switch name {
+	case "Ref", "Condition":
+		return map[string]interface{}{name: arg}
+
+	case "GetAtt":
+		if s, ok := arg.(string); ok {
+			parts := strings.SplitN(s, ".", 2)
+			args := []interface{}{}
+			for _, p := range parts {
+				args = append(args, p)
+			}
+			arg = args
+		}
+	}
+
+	return map[string]interface{}{"Fn::" + name: arg}
+}
@@ -0,0 +1,228 @@
+package buildinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// package the values are injected into
+const versionPackage = "api/pkg/version"
+
+// BuildInfo is the provenance injected into the api binary via ldflags,
+// resources/api/Makefile derives the same values from the same variables
+type BuildInfo struct {
+	Version             string
+	BuildHash           string
+	BuildDate           string
+	VCSDirty            string
+	Tenant              string
+	Environment         string
+	PipelineExecutionID string
+	SourceRepo          string
+}
+
+// FromEnv collects build info from the CodeBuild environment, falling back
+// to the local git checkout
+func FromEnv(tenant, environment string) BuildInfo {
+
+	buildNumber, ok := os.LookupEnv("CODEBUILD_BUILD_NUMBER")
+	if !ok {
+		// default version
+		buildNumber = "0"
+	}
+
+	// pipeline checkouts are clean by definition
+	dirty := "false"
+
+	sourceVersion, ok := os.LookupEnv("CODEBUILD_RESOLVED_SOURCE_VERSION")
+	if !ok {
+		sourceVersion = git("rev-parse", "--short", "HEAD")
+		dirty = vcsDirty()
+	}
+
+	buildDate, ok := os.LookupEnv("BUILD_DATE")
+	if !ok {
+		buildDate = commitDate()
+	}
+
+	return BuildInfo{
+		Version:             fmt.Sprintf("1.0.%s", buildNumber),
+		BuildHash:           sourceVersion,
+		BuildDate:           buildDate,
+		VCSDirty:            dirty,
+		Tenant:              tenant,
+		Environment:         environment,
+		PipelineExecutionID: os.Getenv("PIPELINE_EXECUTION_ID"),
+		SourceRepo:          os.Getenv("SOURCE_REPO"),
+	}
+}
+
+// LDFlags returns the value of the go build -ldflags argument
+func (b BuildInfo) LDFlags() string {
+
+	vars := []struct {
+		name  string
+		value string
+	}{
+		{"Version", b.Version},
+		{"BuildHash", b.BuildHash},
+		{"BuildDate", b.BuildDate},
+		{"VCSDirty", b.VCSDirty},
+		{"Tenant", b.Tenant},
+		{"Environment", b.Environment},
+		{"PipelineExecutionID", b.PipelineExecutionID},
+		{"SourceRepo", b.SourceRepo},
+	}
+
+	// strip symbols and the per build ID so identical inputs give an
+	// identical binary
+	flags := []string{"-s", "-w", "-buildid="}
+
+	for _, v := range vars {
+		if v.value == "" {
+			continue
+		}
+		flags = append(flags, fmt.Sprintf("-X %s.%s=%s", versionPackage, v.name, v.value))
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// GoBuildFlags returns the full set of reproducible go build arguments
+func (b BuildInfo) GoBuildFlags() []string {
+	return []string{"-trimpath", "-ldflags", b.LDFlags()}
+}
+
+// HashInputs are the build inputs which change the behaviour of the binary.
+// Per build provenance (version, commit, date, pipeline execution) is left
+// out, otherwise every pipeline run would redeploy an unchanged function.
+func (b BuildInfo) HashInputs() []string {
+	return []string{"-trimpath", "-s -w -buildid=", b.Tenant, b.Environment}
+}
+
+// commitDate derives the build date from the commit rather than the wall
+// clock, so rebuilding the same commit gives the same binary. The pipeline
+// passes the source action's committer date as COMMIT_DATE, elsewhere
+// SOURCE_DATE_EPOCH or the local git checkout are used.
+func commitDate() string {
+
+	if epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		if secs, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC().Format("20060102")
+		}
+	}
+
+	if date, ok := os.LookupEnv("COMMIT_DATE"); ok {
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			return t.UTC().Format("20060102")
+		}
+	}
+
+	if secs, err := strconv.ParseInt(git("log", "-1", "--format=%ct"), 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC().Format("20060102")
+	}
+
+	// deterministic unknown
+	return "19700101"
+}
+
+// AssetHash hashes the module source along with the build inputs, so the
+// Lambda asset, and therefore the function, only changes when the binary
+// would. Dot files and the local build output are ignored.
+func AssetHash(moduleDir string, inputs ...string) (string, error) {
+
+	h := sha256.New()
+
+	for _, input := range inputs {
+		fmt.Fprintf(h, "input:%s\x00", input)
+	}
+
+	fmt.Fprintf(h, "go:%s\x00", goVersion())
+
+	err := filepath.Walk(moduleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+
+		if path != moduleDir && strings.HasPrefix(name, ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() || !info.Mode().IsRegular() || name == "bootstrap" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		fmt.Fprintf(h, "file:%s\x00", filepath.ToSlash(rel))
+
+		_, err = io.Copy(h, f)
+
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// goVersion of the local toolchain
+func goVersion() string {
+
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// git runs a git command, returning "unknown" outside of a checkout
+func git(args ...string) string {
+
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// vcsDirty reports whether the checkout has uncommitted changes
+func vcsDirty() string {
+
+	status := git("status", "--porcelain")
+
+	switch status {
+	case "unknown":
+		return "unknown"
+	case "":
+		return "false"
+	}
+
+	return "true"
+}
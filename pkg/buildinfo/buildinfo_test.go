@@ -0,0 +1,111 @@
+package buildinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var info = BuildInfo{
+	Version:     "1.0.42",
+	BuildHash:   "0123abc",
+	BuildDate:   "20211015",
+	VCSDirty:    "false",
+	Tenant:      "acme",
+	Environment: "test",
+}
+
+func TestLDFlags(t *testing.T) {
+
+	want := "-s -w -buildid=" +
+		" -X api/pkg/version.Version=1.0.42" +
+		" -X api/pkg/version.BuildHash=0123abc" +
+		" -X api/pkg/version.BuildDate=20211015" +
+		" -X api/pkg/version.VCSDirty=false" +
+		" -X api/pkg/version.Tenant=acme" +
+		" -X api/pkg/version.Environment=test"
+
+	// empty values keep the package defaults
+	if got := info.LDFlags(); got != want {
+		t.Errorf("LDFlags is %q, want %q", got, want)
+	}
+
+	if got, want := info.GoBuildFlags(), []string{"-trimpath", "-ldflags", want}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GoBuildFlags are %q, want %q", got, want)
+	}
+}
+
+func TestHashInputs(t *testing.T) {
+
+	// another pipeline run of the same deployment
+	rebuilt := info
+	rebuilt.Version = "1.0.43"
+	rebuilt.BuildHash = "4567def"
+	rebuilt.BuildDate = "20211016"
+	rebuilt.PipelineExecutionID = "0bd5c2d2"
+
+	if got, want := rebuilt.HashInputs(), info.HashInputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("HashInputs are %q after a rebuild, want %q", got, want)
+	}
+
+	// another deployment
+	other := info
+	other.Tenant = "globex"
+
+	if reflect.DeepEqual(other.HashInputs(), info.HashInputs()) {
+		t.Error("HashInputs ignore the tenant")
+	}
+}
+
+func TestAssetHash(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "buildinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash := func(inputs ...string) string {
+		t.Helper()
+		h, err := AssetHash(dir, inputs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	write("main.go", "package main")
+	base := hash(info.HashInputs()...)
+
+	// dot files and the local build output are not sources
+	write(".git/HEAD", "ref: refs/heads/main")
+	write("bootstrap", "binary")
+	if got := hash(info.HashInputs()...); got != base {
+		t.Error("hash changed with ignored files")
+	}
+
+	write("main.go", "package main // changed")
+	if got := hash(info.HashInputs()...); got == base {
+		t.Error("hash unchanged with a changed source")
+	}
+	base = hash(info.HashInputs()...)
+
+	other := info
+	other.Environment = "prod"
+	if got := hash(other.HashInputs()...); got == base {
+		t.Error("hash unchanged with another environment")
+	}
+}
@@ -0,0 +1,93 @@
+package bundling
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// name of the executable the provided runtimes invoke
+const bootstrap = "bootstrap"
+
+// GoBuild is an awscdk.ILocalBundling which cross compiles a Go command
+// with the local toolchain. It never falls back to Docker, a missing or
+// broken toolchain fails the synth with the reason.
+type GoBuild struct {
+	// directory containing go.mod
+	ModuleDir string
+	// package to build, relative to ModuleDir
+	Package string
+	// target platform
+	GOOS   string
+	GOARCH string
+	// go build arguments, e.g. -trimpath -ldflags ...
+	Flags []string
+	// extra build environment
+	Environment map[string]string
+}
+
+// TryBundle builds the bootstrap executable into outputDir
+func (b *GoBuild) TryBundle(outputDir *string, options *awscdk.BundlingOptions) *bool {
+
+	if err := b.Build(*outputDir); err != nil {
+		// returning false would fall back to docker bundling, a panic
+		// loses the build output in the jsii stack trace
+		log.Fatalf("%v", err)
+	}
+
+	return jsii.Bool(true)
+}
+
+// Build cross compiles the package into dir/bootstrap
+func (b *GoBuild) Build(dir string) error {
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("local bundling of %s needs the go toolchain on PATH, docker bundling is not supported: %w", b.Package, err)
+	}
+
+	version, err := exec.Command(goBin, "env", "GOVERSION").Output()
+	if err != nil {
+		return fmt.Errorf("local bundling of %s: %s is not a working go toolchain: %w", b.Package, goBin, err)
+	}
+
+	args := append([]string{"build", "-o", filepath.Join(dir, bootstrap)}, b.Flags...)
+	args = append(args, b.Package)
+
+	cmd := exec.Command(goBin, args...)
+	cmd.Dir = b.ModuleDir
+	cmd.Env = append(os.Environ(),
+		"GOOS="+b.GOOS,
+		"GOARCH="+b.GOARCH,
+		"CGO_ENABLED=0",
+		"GO111MODULE=on",
+	)
+	for k, v := range b.Environment {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+
+	fmt.Fprintf(os.Stderr, "Bundling %s for %s/%s with %s\n", b.Package, b.GOOS, b.GOARCH, strings.TrimSpace(string(version)))
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("local bundling of %s failed: %w\n%s", b.Package, err, stderr.String())
+	}
+
+	return nil
+}
+
+// Image is the placeholder docker image the CDK requires alongside local
+// bundling, it is never pulled
+func (b *GoBuild) Image() awscdk.DockerImage {
+	return awscdk.DockerImage_FromRegistry(jsii.String("local-bundling-only"))
+}
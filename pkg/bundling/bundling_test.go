@@ -0,0 +1,88 @@
+package bundling
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// module writes a main package into a module of its own
+func module(t *testing.T, main string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"go.mod":         "module example\n\ngo 1.16\n",
+		"cmd/fn/main.go": main,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func goBuild(dir string) *GoBuild {
+	return &GoBuild{
+		ModuleDir: dir,
+		Package:   "./cmd/fn",
+		GOOS:      "linux",
+		GOARCH:    "arm64",
+		Flags:     []string{"-trimpath"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+
+	dir := module(t, "package main\n\nfunc main() {}\n")
+	out := t.TempDir()
+
+	if err := goBuild(dir).Build(out); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(out, bootstrap)); err != nil || info.Size() == 0 {
+		t.Errorf("no bootstrap built: %v", err)
+	}
+}
+
+func TestBuildFailed(t *testing.T) {
+
+	dir := module(t, "package main\n\nfunc main() { undefined() }\n")
+
+	err := goBuild(dir).Build(t.TempDir())
+
+	// the reason and the compiler output
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("error is %v, want the go build exit status", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "local bundling of ./cmd/fn failed") || !strings.Contains(msg, "undefined: undefined") {
+		t.Errorf("error is %q", msg)
+	}
+}
+
+func TestBuildWithoutToolchain(t *testing.T) {
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", t.TempDir())
+	defer os.Setenv("PATH", path)
+
+	err := goBuild(module(t, "package main\n\nfunc main() {}\n")).Build(t.TempDir())
+
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("error is %v, want %v", err, exec.ErrNotFound)
+	}
+	if !strings.Contains(err.Error(), "needs the go toolchain on PATH") {
+		t.Errorf("error is %q", err)
+	}
+}
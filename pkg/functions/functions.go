@@ -0,0 +1,255 @@
+package functions
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"permission-boundary-pipeline-cdk/pkg/buildinfo"
+	"permission-boundary-pipeline-cdk/pkg/bundling"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/awslambdaeventsources"
+	"github.com/aws/aws-cdk-go/awscdk/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/awss3"
+	"github.com/aws/aws-cdk-go/awscdk/awss3assets"
+	"github.com/aws/aws-cdk-go/awscdk/awss3notifications"
+	"github.com/aws/aws-cdk-go/awscdk/awssqs"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+type FunctionProps struct {
+	Command   Command             ``
+	BuildInfo buildinfo.BuildInfo ``
+	// environment shared by every function
+	Variables map[string]string ``
+	// routes http triggers, may be nil when no function needs one
+	HttpApi awsapigatewayv2.HttpApi ``
+	// attaches the function to a VPC, may be nil
+	Vpc            awsec2.IVpc             ``
+	VpcSubnets     *awsec2.SubnetSelection ``
+	SecurityGroups []awsec2.ISecurityGroup ``
+}
+
+// LogRetention applies to function logs and the logs kept alongside them
+// e.g. the API access log
+const LogRetention = awslogs.RetentionDays_ONE_WEEK
+
+// LogRetentionDays is LogRetention for log groups created as L1 resources
+const LogRetentionDays = 7
+
+// lambda architectures by manifest name
+var goArch = map[string]string{"arm64": "arm64", "x86_64": "amd64"}
+
+// GoFunction builds a command with the shared bundling and version
+// injection, then wires up the triggers from its manifest
+func GoFunction(scope constructs.Construct, id string, props *FunctionProps) awslambda.Function {
+
+	construct := awscdk.NewConstruct(scope, &id)
+
+	manifest := props.Command.Manifest
+
+	architecture := awslambda.Architecture_ARM_64()
+	if manifest.Architecture == "x86_64" {
+		architecture = awslambda.Architecture_X86_64()
+	} else {
+		manifest.Architecture = "arm64"
+	}
+
+	// cross compiled locally, no docker
+	build := &bundling.GoBuild{
+		ModuleDir: props.Command.ModuleDir,
+		Package:   props.Command.Package,
+		GOOS:      "linux",
+		GOARCH:    goArch[manifest.Architecture],
+		Flags:     props.BuildInfo.GoBuildFlags(),
+	}
+
+	// reproducible builds, the asset hash only tracks source + build inputs
+	// so an unchanged function isn't redeployed on every synth
+	assetHash, err := buildinfo.AssetHash(
+		build.ModuleDir,
+		append(props.BuildInfo.HashInputs(), build.Package, build.GOOS, build.GOARCH)...,
+	)
+	if err != nil {
+		log.Fatal("Cannot hash function source", err)
+	}
+
+	environment := map[string]*string{}
+	for k, v := range props.Variables {
+		environment[k] = jsii.String(v)
+	}
+	for k, v := range manifest.Environment {
+		environment[k] = jsii.String(v)
+	}
+
+	functionProps := &awslambda.FunctionProps{
+		Runtime: awslambda.Runtime_PROVIDED_AL2(),
+		Handler: jsii.String("bootstrap"),
+		Code: awslambda.Code_FromAsset(jsii.String(build.ModuleDir), &awss3assets.AssetOptions{
+			AssetHashType: awscdk.AssetHashType_CUSTOM,
+			AssetHash:     jsii.String(assetHash),
+			Bundling: &awscdk.BundlingOptions{
+				Image: build.Image(),
+				Local: build,
+			},
+		}),
+		Tracing:       awslambda.Tracing_ACTIVE,
+		LogRetention:  LogRetention,
+		Architectures: &[]awslambda.Architecture{architecture},
+		Environment:   &environment,
+	}
+
+	if props.Vpc != nil {
+		functionProps.Vpc = props.Vpc
+		functionProps.VpcSubnets = props.VpcSubnets
+		functionProps.SecurityGroups = &props.SecurityGroups
+	}
+
+	if manifest.Memory != 0 {
+		functionProps.MemorySize = jsii.Number(manifest.Memory)
+	}
+
+	if manifest.Timeout != 0 {
+		functionProps.Timeout = awscdk.Duration_Seconds(jsii.Number(manifest.Timeout))
+	}
+
+	function := awslambda.NewFunction(construct, jsii.String("Lambda"), functionProps)
+
+	var integration awsapigatewayv2.IHttpRouteIntegration
+
+	for i, trigger := range manifest.Triggers {
+
+		triggerID := jsii.String(fmt.Sprintf("Trigger%d", i))
+
+		switch {
+		case trigger.HTTP != nil:
+			if props.HttpApi == nil {
+				log.Fatalf("%s: http trigger without an HTTP API", manifest.Name)
+			}
+
+			if integration == nil {
+				integration = awsapigatewayv2integrations.NewLambdaProxyIntegration(&awsapigatewayv2integrations.LambdaProxyIntegrationProps{
+					Handler:              function,
+					PayloadFormatVersion: awsapigatewayv2.PayloadFormatVersion_VERSION_1_0(),
+				})
+			}
+
+			methods := []awsapigatewayv2.HttpMethod{}
+			for _, method := range trigger.HTTP.Methods {
+				methods = append(methods, awsapigatewayv2.HttpMethod(method))
+			}
+			if len(methods) == 0 {
+				methods = append(methods, awsapigatewayv2.HttpMethod_ANY)
+			}
+
+			props.HttpApi.AddRoutes(&awsapigatewayv2.AddRoutesOptions{
+				Integration: integration,
+				Path:        jsii.String(trigger.HTTP.Path),
+				Methods:     &methods,
+			})
+
+		case trigger.SQS != nil:
+			var queue awssqs.IQueue
+
+			if trigger.SQS.QueueArn != "" {
+				queue = awssqs.Queue_FromQueueArn(construct, triggerID, jsii.String(trigger.SQS.QueueArn))
+			} else {
+				// visibility must cover the function timeout, AWS recommends 6x
+				queue = awssqs.NewQueue(construct, triggerID, &awssqs.QueueProps{
+					VisibilityTimeout: awscdk.Duration_Seconds(jsii.Number(6 * timeoutSeconds(manifest))),
+					Encryption:        awssqs.QueueEncryption_KMS_MANAGED,
+				})
+			}
+
+			sqsProps := &awslambdaeventsources.SqsEventSourceProps{}
+			if trigger.SQS.BatchSize != 0 {
+				sqsProps.BatchSize = jsii.Number(trigger.SQS.BatchSize)
+			}
+
+			function.AddEventSource(awslambdaeventsources.NewSqsEventSource(queue, sqsProps))
+
+		case trigger.Schedule != nil:
+			rule := awsevents.NewRule(construct, triggerID, &awsevents.RuleProps{
+				Schedule: awsevents.Schedule_Expression(jsii.String(trigger.Schedule.Expression)),
+			})
+
+			rule.AddTarget(awseventstargets.NewLambdaFunction(function, &awseventstargets.LambdaFunctionProps{}))
+
+		case trigger.S3 != nil:
+			var bucket awss3.IBucket
+
+			if trigger.S3.Bucket != "" {
+				bucket = awss3.Bucket_FromBucketName(construct, triggerID, jsii.String(trigger.S3.Bucket))
+			} else {
+				bucket = awss3.NewBucket(construct, triggerID, &awss3.BucketProps{
+					Encryption:        awss3.BucketEncryption_S3_MANAGED,
+					BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+					EnforceSSL:        jsii.Bool(true),
+				})
+			}
+
+			events := trigger.S3.Events
+			if len(events) == 0 {
+				events = []string{"s3:ObjectCreated:*"}
+			}
+
+			var filters []*awss3.NotificationKeyFilter
+			if trigger.S3.Prefix != "" || trigger.S3.Suffix != "" {
+				filters = append(filters, &awss3.NotificationKeyFilter{
+					Prefix: nonEmpty(trigger.S3.Prefix),
+					Suffix: nonEmpty(trigger.S3.Suffix),
+				})
+			}
+
+			for _, event := range events {
+				bucket.AddEventNotification(
+					awss3.EventType(s3Events[event]),
+					awss3notifications.NewLambdaDestination(function),
+					filters...,
+				)
+			}
+		}
+	}
+
+	return function
+}
+
+// ConstructID turns a function name into a construct ID, e.g. nightly-report
+// becomes NightlyReport
+func ConstructID(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+	for i, part := range parts {
+		r, size := utf8.DecodeRuneInString(part)
+		parts[i] = string(unicode.ToUpper(r)) + part[size:]
+	}
+	return strings.Join(parts, "")
+}
+
+// timeoutSeconds is the effective function timeout
+func timeoutSeconds(m Manifest) float64 {
+	if m.Timeout == 0 {
+		// Lambda default
+		return 3
+	}
+	return m.Timeout
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return jsii.String(s)
+}
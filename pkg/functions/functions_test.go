@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/cxapi"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// synthFunction synthesizes a function with a single trigger, returning
+// the template and the error annotations
+func synthFunction(t *testing.T, trigger Trigger) (assertions.Template, []string) {
+	t.Helper()
+
+	root := t.TempDir()
+	writeCommand(t, root, "worker", "worker", `{}`)
+
+	// asset hashes only, the bundling is left to the bundling tests
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Outdir:             jsii.String(t.TempDir()),
+		AnalyticsReporting: jsii.Bool(false),
+		Context:            &map[string]interface{}{"aws:cdk:bundling-stacks": []string{}},
+	})
+	stack := awscdk.NewStack(app, jsii.String("Test"), nil)
+
+	GoFunction(stack, "Worker", &FunctionProps{
+		Command: Command{
+			ModuleDir: filepath.Join(root, "worker"),
+			Package:   "./cmd/worker",
+			Manifest:  Manifest{Name: "worker", Timeout: 10, Triggers: []Trigger{trigger}},
+		},
+	})
+
+	errs := []string{}
+	for _, message := range *app.Synth(nil).GetStackArtifact(stack.ArtifactId()).Messages() {
+		if message.Level == cxapi.SynthesisMessageLevel_ERROR {
+			errs = append(errs, fmt.Sprintf("%s: %v", *message.Id, message.Entry.Data))
+		}
+	}
+
+	return assertions.Template_FromStack(stack), errs
+}
+
+// only returns the properties of the one resource of a type
+func only(t *testing.T, template assertions.Template, typ string) map[string]interface{} {
+	t.Helper()
+
+	found := *template.FindResources(jsii.String(typ), nil)
+	if len(found) != 1 {
+		t.Fatalf("%d %s, want 1", len(found), typ)
+	}
+	for _, resource := range found {
+		properties, _ := (*resource)["Properties"].(map[string]interface{})
+		return properties
+	}
+	return nil
+}
+
+func asJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestSQSTrigger(t *testing.T) {
+
+	template, errs := synthFunction(t, Trigger{SQS: &SQSTrigger{BatchSize: 5}})
+	for _, err := range errs {
+		t.Error(err)
+	}
+
+	mapping := only(t, template, "AWS::Lambda::EventSourceMapping")
+	if mapping["BatchSize"] != 5.0 {
+		t.Errorf("BatchSize is %v", mapping["BatchSize"])
+	}
+
+	// the queue is created, its visibility six times the function timeout
+	queue := only(t, template, "AWS::SQS::Queue")
+	if queue["VisibilityTimeout"] != 60.0 || queue["KmsMasterKeyId"] != "alias/aws/sqs" {
+		t.Errorf("queue is %v", queue)
+	}
+}
+
+func TestScheduleTrigger(t *testing.T) {
+
+	template, errs := synthFunction(t, Trigger{Schedule: &ScheduleTrigger{Expression: "rate(1 day)"}})
+	for _, err := range errs {
+		t.Error(err)
+	}
+
+	rule := only(t, template, "AWS::Events::Rule")
+	if rule["ScheduleExpression"] != "rate(1 day)" {
+		t.Errorf("ScheduleExpression is %v", rule["ScheduleExpression"])
+	}
+	if targets := asJSON(t, rule["Targets"]); !containsAll(targets, `"Fn::GetAtt":["WorkerLambda`, `"Arn"]`) {
+		t.Errorf("Targets are %s", targets)
+	}
+
+	// EventBridge may invoke the function
+	if permission := only(t, template, "AWS::Lambda::Permission"); permission["Principal"] != "events.amazonaws.com" {
+		t.Errorf("permission is %v", permission)
+	}
+}
+
+func TestS3Trigger(t *testing.T) {
+
+	template, errs := synthFunction(t, Trigger{S3: &S3Trigger{Prefix: "uploads/"}})
+	for _, err := range errs {
+		t.Error(err)
+	}
+
+	// created private and encrypted, notifying on creates by default
+	bucket := only(t, template, "AWS::S3::Bucket")
+	if bucket["BucketEncryption"] == nil || bucket["PublicAccessBlockConfiguration"] == nil {
+		t.Errorf("bucket is %v", bucket)
+	}
+
+	notifications := asJSON(t, only(t, template, "Custom::S3BucketNotifications")["NotificationConfiguration"])
+	if !containsAll(notifications, `"Events":["s3:ObjectCreated:*"]`, `"Name":"prefix","Value":"uploads/"`, `"LambdaFunctionArn":{"Fn::GetAtt":["WorkerLambda`) {
+		t.Errorf("NotificationConfiguration is %s", notifications)
+	}
+}
+
+func containsAll(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
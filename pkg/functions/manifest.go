@@ -0,0 +1,261 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"api/pkg/ratelimit"
+)
+
+// ManifestFile marks a command as a Lambda function, commands without one
+// (e.g. developer tooling) are not deployed
+const ManifestFile = "lambda.json"
+
+// Manifest is the per command function configuration
+type Manifest struct {
+	// function name, defaults to the command directory
+	Name string `json:"name"`
+	// memory in MB, 0 uses the Lambda default
+	Memory float64 `json:"memory"`
+	// timeout in seconds, 0 uses the Lambda default
+	Timeout float64 `json:"timeout"`
+	// arm64 (default) or x86_64
+	Architecture string `json:"architecture"`
+	// extra environment, overrides the shared variables
+	Environment map[string]string `json:"environment"`
+	Triggers    []Trigger         `json:"triggers"`
+}
+
+// Trigger invokes the function, exactly one source must be set
+type Trigger struct {
+	HTTP     *HTTPTrigger     `json:"http,omitempty"`
+	SQS      *SQSTrigger      `json:"sqs,omitempty"`
+	Schedule *ScheduleTrigger `json:"schedule,omitempty"`
+	S3       *S3Trigger       `json:"s3,omitempty"`
+}
+
+// HTTPTrigger routes requests from the shared HTTP API
+type HTTPTrigger struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	// stage throttling for these routes, overrides the API default
+	Throttle *Throttle `json:"throttle,omitempty"`
+}
+
+// Throttle is an API Gateway token bucket, 0 leaves a limit unset
+type Throttle struct {
+	// steady state requests per second
+	Rate float64 `json:"rate"`
+	// requests allowed above the rate
+	Burst float64 `json:"burst"`
+}
+
+// Quota checks the limits with the same rules as the api rate limiter
+func (t Throttle) Quota() (ratelimit.Quota, error) {
+	return ratelimit.NewQuota(t.Rate, t.Burst)
+}
+
+// SQSTrigger consumes a queue, a new queue is created when no ARN is given
+type SQSTrigger struct {
+	QueueArn  string  `json:"queueArn"`
+	BatchSize float64 `json:"batchSize"`
+}
+
+// ScheduleTrigger is an EventBridge rate() or cron() expression
+type ScheduleTrigger struct {
+	Expression string `json:"expression"`
+}
+
+// S3Trigger receives bucket notifications, a new bucket is created when no
+// name is given
+type S3Trigger struct {
+	Bucket string `json:"bucket"`
+	// e.g. s3:ObjectCreated:*, the default
+	Events []string `json:"events"`
+	Prefix string   `json:"prefix"`
+	Suffix string   `json:"suffix"`
+}
+
+// Command is a main package with a manifest
+type Command struct {
+	// directory containing go.mod
+	ModuleDir string
+	// package relative to ModuleDir, e.g. ./cmd/api
+	Package  string
+	Manifest Manifest
+}
+
+// S3 notification names as used by the S3 API, mapped to the CDK enum
+var s3Events = map[string]string{
+	"s3:ObjectCreated:*":                       "OBJECT_CREATED",
+	"s3:ObjectCreated:Put":                     "OBJECT_CREATED_PUT",
+	"s3:ObjectCreated:Post":                    "OBJECT_CREATED_POST",
+	"s3:ObjectCreated:Copy":                    "OBJECT_CREATED_COPY",
+	"s3:ObjectCreated:CompleteMultipartUpload": "OBJECT_CREATED_COMPLETE_MULTIPART_UPLOAD",
+	"s3:ObjectRemoved:*":                       "OBJECT_REMOVED",
+	"s3:ObjectRemoved:Delete":                  "OBJECT_REMOVED_DELETE",
+	"s3:ObjectRemoved:DeleteMarkerCreated":     "OBJECT_REMOVED_DELETE_MARKER_CREATED",
+	"s3:ObjectRestore:Post":                    "OBJECT_RESTORE_POST",
+	"s3:ObjectRestore:Completed":               "OBJECT_RESTORE_COMPLETED",
+}
+
+var architectures = map[string]bool{"arm64": true, "x86_64": true}
+
+var methods = map[string]bool{
+	"ANY": true, "DELETE": true, "GET": true, "HEAD": true,
+	"OPTIONS": true, "PATCH": true, "POST": true, "PUT": true,
+}
+
+// Discover finds every <root>/*/cmd/* main package carrying a manifest,
+// ordered by name
+func Discover(root string) ([]Command, error) {
+
+	dirs, err := filepath.Glob(filepath.Join(root, "*", "cmd", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []Command
+
+	names := map[string]string{}
+
+	for _, dir := range dirs {
+
+		manifestPath := filepath.Join(dir, ManifestFile)
+
+		raw, err := ioutil.ReadFile(manifestPath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		moduleDir := filepath.Dir(filepath.Dir(dir))
+
+		if _, err := os.Stat(filepath.Join(moduleDir, "go.mod")); err != nil {
+			return nil, fmt.Errorf("%s: %s is not a go module", manifestPath, moduleDir)
+		}
+
+		if !isMain(dir) {
+			return nil, fmt.Errorf("%s: %s is not a main package", manifestPath, dir)
+		}
+
+		var manifest Manifest
+
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestPath, err)
+		}
+
+		if manifest.Name == "" {
+			manifest.Name = filepath.Base(dir)
+		}
+
+		if err := manifest.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", manifestPath, err)
+		}
+
+		if other, ok := names[manifest.Name]; ok {
+			return nil, fmt.Errorf("%s: function name %q is already used by %s", manifestPath, manifest.Name, other)
+		}
+		names[manifest.Name] = manifestPath
+
+		commands = append(commands, Command{
+			ModuleDir: moduleDir,
+			Package:   "./" + filepath.ToSlash(filepath.Join("cmd", filepath.Base(dir))),
+			Manifest:  manifest,
+		})
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Manifest.Name < commands[j].Manifest.Name
+	})
+
+	return commands, nil
+}
+
+// isMain reports whether dir holds a main package
+func isMain(dir string) bool {
+
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly)
+	if err != nil {
+		return false
+	}
+
+	_, ok := pkgs["main"]
+
+	return ok
+}
+
+func (m Manifest) validate() error {
+
+	if m.Architecture != "" && !architectures[m.Architecture] {
+		return fmt.Errorf("unknown architecture %q", m.Architecture)
+	}
+
+	if m.Memory < 0 || m.Timeout < 0 {
+		return fmt.Errorf("memory and timeout must be positive")
+	}
+
+	for i, t := range m.Triggers {
+
+		set := 0
+
+		if t.HTTP != nil {
+			set++
+
+			if !strings.HasPrefix(t.HTTP.Path, "/") {
+				return fmt.Errorf("trigger %d: http path %q must start with /", i, t.HTTP.Path)
+			}
+
+			for _, method := range t.HTTP.Methods {
+				if !methods[method] {
+					return fmt.Errorf("trigger %d: unknown http method %q", i, method)
+				}
+			}
+
+			if th := t.HTTP.Throttle; th != nil {
+				if _, err := th.Quota(); err != nil {
+					return fmt.Errorf("trigger %d: throttle: %w", i, err)
+				}
+			}
+		}
+
+		if t.SQS != nil {
+			set++
+		}
+
+		if t.Schedule != nil {
+			set++
+
+			e := t.Schedule.Expression
+			if !strings.HasPrefix(e, "rate(") && !strings.HasPrefix(e, "cron(") {
+				return fmt.Errorf("trigger %d: schedule %q must be a rate() or cron() expression", i, e)
+			}
+		}
+
+		if t.S3 != nil {
+			set++
+
+			for _, event := range t.S3.Events {
+				if _, ok := s3Events[event]; !ok {
+					return fmt.Errorf("trigger %d: unsupported s3 event %q", i, event)
+				}
+			}
+		}
+
+		if set != 1 {
+			return fmt.Errorf("trigger %d: exactly one of http, sqs, schedule or s3 must be set", i)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package functions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestValidate(t *testing.T) {
+
+	http := func(path string, methods ...string) Trigger {
+		return Trigger{HTTP: &HTTPTrigger{Path: path, Methods: methods}}
+	}
+
+	throttled := func(rate, burst float64) Trigger {
+		trigger := http("/report", "GET")
+		trigger.HTTP.Throttle = &Throttle{Rate: rate, Burst: burst}
+		return trigger
+	}
+
+	tests := []struct {
+		name     string
+		manifest Manifest
+		err      string
+	}{
+		{"valid", Manifest{Architecture: "arm64", Triggers: []Trigger{http("/report", "GET"), throttled(5, 10)}}, ""},
+		{"x86", Manifest{Architecture: "x86_64"}, ""},
+		{"unknown architecture", Manifest{Architecture: "amd64"}, "unknown architecture"},
+		{"negative memory", Manifest{Memory: -1}, "must be positive"},
+		{"relative path", Manifest{Triggers: []Trigger{http("report", "GET")}}, "must start with /"},
+		{"unknown method", Manifest{Triggers: []Trigger{http("/report", "FETCH")}}, "unknown http method"},
+		{"negative rate", Manifest{Triggers: []Trigger{throttled(-1, 10)}}, "throttle"},
+		{"negative burst", Manifest{Triggers: []Trigger{throttled(5, -10)}}, "throttle"},
+		{"fractional burst", Manifest{Triggers: []Trigger{throttled(5, 1.5)}}, "throttle"},
+		{"no source", Manifest{Triggers: []Trigger{{}}}, "exactly one"},
+		{"two sources", Manifest{Triggers: []Trigger{{SQS: &SQSTrigger{}, Schedule: &ScheduleTrigger{Expression: "rate(1 day)"}}}}, "exactly one"},
+		{"bad schedule", Manifest{Triggers: []Trigger{{Schedule: &ScheduleTrigger{Expression: "every day"}}}}, "rate() or cron()"},
+		{"bad s3 event", Manifest{Triggers: []Trigger{{S3: &S3Trigger{Events: []string{"s3:ObjectCreated"}}}}}, "unsupported s3 event"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error %s", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// writeCommand lays out <root>/<module>/cmd/<command> with a manifest
+func writeCommand(t *testing.T, root, module, command, manifest string) {
+	t.Helper()
+
+	dir := filepath.Join(root, module, "cmd", command)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(root, module, "go.mod"): "module " + module + "\n",
+		filepath.Join(dir, "main.go"):         "package main\n\nfunc main() {}\n",
+		filepath.Join(dir, ManifestFile):      manifest,
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+
+	root := t.TempDir()
+	writeCommand(t, root, "api", "api", `{"triggers": [{"http": {"path": "/hello", "methods": ["GET"]}}]}`)
+	writeCommand(t, root, "jobs", "nightly", `{"name": "nightly-report", "architecture": "x86_64"}`)
+
+	// no manifest, not deployed
+	if err := os.MkdirAll(filepath.Join(root, "api", "cmd", "replay"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := Discover(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range commands {
+		got = append(got, c.Manifest.Name+" "+c.Package)
+	}
+	if strings.Join(got, ",") != "api ./cmd/api,nightly-report ./cmd/nightly" {
+		t.Errorf("discovered %v", got)
+	}
+}
+
+func TestDiscoverInvalid(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		commands map[string]string // module/command to manifest
+		err      string
+	}{
+		{"duplicate names", map[string]string{"api/api": `{"name": "report"}`, "jobs/report": `{}`}, `"report" is already used`},
+		{"invalid json", map[string]string{"api/api": `{"memory": "lots"}`}, "cannot unmarshal"},
+		{"invalid architecture", map[string]string{"api/api": `{"architecture": "sparc"}`}, "unknown architecture"},
+		{"invalid trigger", map[string]string{"api/api": `{"triggers": [{"sqs": {}, "http": {"path": "/"}}]}`}, "exactly one"},
+		{"bad throttle", map[string]string{"api/api": `{"triggers": [{"http": {"path": "/", "throttle": {"rate": 5, "burst": 0.5}}}]}`}, "throttle"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			root := t.TempDir()
+			for path, manifest := range tt.commands {
+				parts := strings.SplitN(path, "/", 2)
+				writeCommand(t, root, parts[0], parts[1], manifest)
+			}
+
+			if _, err := Discover(root); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConstructID(t *testing.T) {
+	for name, want := range map[string]string{
+		"api":            "Api",
+		"nightly-report": "NightlyReport",
+		"db_rotate.v2":   "DbRotateV2",
+	} {
+		if got := ConstructID(name); got != want {
+			t.Errorf("ConstructID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package hosting
+
+import (
+	"encoding/json"
+	"log"
+
+	"permission-boundary-pipeline-cdk/pkg/functions"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/awslogs"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+// accessLogFormat is the JSON access log line, rqID is the Lambda request ID
+// and matches the rqID field chilogger writes so the two can be joined
+var accessLogFormat = map[string]string{
+	"requestId":          "$context.requestId",
+	"rqID":               "$context.integration.requestId",
+	"requestTime":        "$context.requestTimeEpoch",
+	"ip":                 "$context.identity.sourceIp",
+	"userAgent":          "$context.identity.userAgent",
+	"method":             "$context.httpMethod",
+	"path":               "$context.path",
+	"protocol":           "$context.protocol",
+	"route":              "$context.routeKey",
+	"status":             "$context.status",
+	"responseLength":     "$context.responseLength",
+	"latency":            "$context.responseLatency",
+	"integrationStatus":  "$context.integrationStatus",
+	"integrationLatency": "$context.integrationLatency",
+	"integrationError":   "$context.integrationErrorMessage",
+	"error":              "$context.error.message",
+}
+
+// AccessLogStack logs every request reaching the $default stage, including
+// those API Gateway rejects before invoking a function. The log group is an
+// L1 resource, the boundary requires the tenant tag on creation and the L2
+// construct does not support tags in this CDK version.
+func AccessLogStack(scope constructs.Construct, id string, stage awsapigatewayv2.CfnStage, tenant string) awslogs.CfnLogGroup {
+
+	// keeps the construct path, and logical ID, of the L2 log group
+	construct := awscdk.NewConstruct(scope, &id)
+
+	logGroup := awslogs.NewCfnLogGroup(construct, jsii.String("Resource"), &awslogs.CfnLogGroupProps{
+		RetentionInDays: jsii.Number(functions.LogRetentionDays),
+	})
+	logGroup.ApplyRemovalPolicy(awscdk.RemovalPolicy_RETAIN, nil)
+	logGroup.AddPropertyOverride(jsii.String("Tags"), []map[string]string{{"Key": util.TenantTag, "Value": tenant}})
+
+	format, err := json.Marshal(accessLogFormat)
+	if err != nil {
+		log.Fatal("Cannot build the access log format", err)
+	}
+
+	stage.SetAccessLogSettings(&awsapigatewayv2.CfnStage_AccessLogSettingsProperty{
+		DestinationArn: logGroup.AttrArn(),
+		Format:         jsii.String(string(format)),
+	})
+
+	return logGroup
+}
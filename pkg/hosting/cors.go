@@ -0,0 +1,44 @@
+package hosting
+
+import (
+	"log"
+	"strings"
+
+	"api/pkg/cors"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// corsPreflight maps the policy onto the HTTP API, nil when no origin is allowed
+func corsPreflight(props *cors.Policy, environment string) *awsapigatewayv2.CorsPreflightOptions {
+
+	origins := props.OriginsFor(environment)
+	if len(origins) == 0 {
+		return nil
+	}
+
+	for _, origin := range origins {
+		// browsers refuse credentials with a wildcard origin
+		if origin == "*" && props.Credentials {
+			log.Fatal("Cannot allow CORS credentials from any origin")
+		}
+	}
+
+	methods := []awsapigatewayv2.CorsHttpMethod{}
+	for _, method := range props.Methods {
+		methods = append(methods, awsapigatewayv2.CorsHttpMethod(strings.ToUpper(strings.TrimSpace(method))))
+	}
+
+	log.Printf("Allowing CORS from %s\n", strings.Join(origins, " "))
+
+	return &awsapigatewayv2.CorsPreflightOptions{
+		AllowOrigins:     jsii.Strings(origins...),
+		AllowMethods:     &methods,
+		AllowHeaders:     jsii.Strings(props.Headers...),
+		ExposeHeaders:    jsii.Strings(props.ExposeHeaders...),
+		AllowCredentials: jsii.Bool(props.Credentials),
+		MaxAge:           awscdk.Duration_Seconds(jsii.Number(float64(props.MaxAge))),
+	}
+}
@@ -2,116 +2,170 @@ package hosting
 
 import (
 	"fmt"
-	"os"
-	"time"
+	"log"
+	"path"
+	"permission-boundary-pipeline-cdk/pkg/buildinfo"
+	"permission-boundary-pipeline-cdk/pkg/functions"
+	"permission-boundary-pipeline-cdk/pkg/observability"
+	"permission-boundary-pipeline-cdk/pkg/secrets"
+	"permission-boundary-pipeline-cdk/pkg/storage"
+	"permission-boundary-pipeline-cdk/pkg/util"
+	"sort"
+
+	"api/pkg/cors"
+	apisecrets "api/pkg/secrets"
 
 	"github.com/aws/aws-cdk-go/awscdk"
 	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
-	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/awsdynamodb"
 	"github.com/aws/aws-cdk-go/awscdk/awsiam"
 	"github.com/aws/aws-cdk-go/awscdk/awslambda"
-	"github.com/aws/aws-cdk-go/awscdk/awslambdago"
-	"github.com/aws/aws-cdk-go/awscdk/awslogs"
 	"github.com/aws/jsii-runtime-go"
 
 	"github.com/aws/constructs-go/constructs/v3"
 )
 
+// directory searched for resources/*/cmd/* functions
+const resourcesDir = "resources"
+
 type HostingProps struct {
-	Tenant           string                  ``
-	Environment      string                  ``
-	Appplication     string                  ``
-	NestedStackProps awscdk.NestedStackProps ``
+	Tenant           string                   ``
+	Environment      string                   ``
+	Appplication     string                   ``
+	Alarms           observability.AlarmProps ``
+	Table            storage.TableProps       ``
+	Secrets          secrets.SecretsProps     ``
+	Vpc              VpcProps                 ``
+	Throttle         ThrottleProps            ``
+	Cors             cors.Policy              ``
+	NestedStackProps awscdk.NestedStackProps  ``
+	// searched for */cmd/* functions, resources/ when empty
+	ResourcesDir string ``
 }
 
 func HostingStack(scope constructs.Construct, id string, props *HostingProps) awscdk.Construct {
 
 	construct := awscdk.NewConstruct(scope, &id)
 
-	buildNumber, ok := os.LookupEnv("CODEBUILD_BUILD_NUMBER")
-	if !ok {
-		// default version
-		buildNumber = "0"
+	// provenance injected into every binary
+	buildInfo := buildinfo.FromEnv(props.Tenant, props.Environment)
+
+	// every command with a manifest becomes a function
+	root := props.ResourcesDir
+	if root == "" {
+		root = resourcesDir
+	}
+	commands, err := functions.Discover(root)
+	if err != nil {
+		log.Fatal("Cannot discover functions", err)
 	}
 
-	sourceVersion, ok := os.LookupEnv("CODEBUILD_RESOLVED_SOURCE_VERSION")
-	if !ok {
-		sourceVersion = "unknown"
+	naming := util.Naming{Tenant: props.Tenant, Environment: props.Environment, Application: props.Appplication}
+
+	variables := map[string]string{
+		"LOG_LEVEL":   "DEBUG",
+		"TENANT":      props.Tenant,
+		"ENVIRONMENT": props.Environment,
+		"SSM_PATH":    naming.ParameterPath(),
 	}
 
-	buildDate, ok := os.LookupEnv("BUILD_DATE")
-	if !ok {
-		t := time.Now()
-		buildDate = t.Format("20060102")
+	// optional application table
+	var table awsdynamodb.Table
+
+	if props.Table.Enabled {
+		table = storage.TableStack(construct, "Table", &props.Table)
+
+		variables["TABLE_NAME"] = *table.TableName()
+
+		awscdk.NewCfnOutput(construct, jsii.String("TableName"), &awscdk.CfnOutputProps{
+			Value: table.TableName(),
+		})
 	}
 
-	// Go build options
-	bundlingOptions := &awslambdago.BundlingOptions{
-		GoBuildFlags: &[]*string{jsii.String(fmt.Sprintf(`-ldflags "-s -w
-			-X api/pkg/version.Version=1.0.%s
-			-X api/pkg/version.BuildHash=%s
-			-X api/pkg/version.BuildDate=%s
-			"`,
-			buildNumber,
-			sourceVersion,
-			buildDate,
-		)),
-		},
-		Environment: &map[string]*string{
-			"GOARCH":      jsii.String("arm64"),
-			"GO111MODULE": jsii.String("on"),
-			"GOOS":        jsii.String("linux"),
-		},
+	// declared secrets, readable by every function
+	applicationSecrets := secrets.SecretsStack(construct, "Secrets", &props.Secrets, naming)
+
+	secretNames := make([]string, 0, len(applicationSecrets))
+	for name, secret := range applicationSecrets {
+		variables[apisecrets.EnvVar(name)] = *secret.SecretArn()
+		secretNames = append(secretNames, name)
 	}
+	sort.Strings(secretNames)
+
+	// optional VPC attachment
+	network := VpcStack(construct, "Network", &props.Vpc, props.Tenant)
 
-	// webhook lambda
-	apiLambda := awslambdago.NewGoFunction(construct, jsii.String("Lambda"), &awslambdago.GoFunctionProps{
-		Runtime:      awslambda.Runtime_PROVIDED_AL2(),
-		Entry:        jsii.String("resources/api/cmd/api"),
-		Bundling:     bundlingOptions,
-		Tracing:      awslambda.Tracing_ACTIVE,
-		LogRetention: awslogs.RetentionDays_ONE_WEEK,
-		Architectures: &[]awslambda.Architecture{
-			awslambda.Architecture_ARM_64(),
-		},
-		Environment: &map[string]*string{
-			"LOG_LEVEL": jsii.String("DEBUG"),
-		},
-		ModuleDir: jsii.String("resources/api/go.mod"),
+	//
+	httpapi := awsapigatewayv2.NewHttpApi(construct, jsii.String("ApplicationAPI"), &awsapigatewayv2.HttpApiProps{
+		CorsPreflight:      corsPreflight(&props.Cors, props.Environment),
+		CreateDefaultStage: jsii.Bool(false),
 	})
 
-	apiLambda.Role().AddToPrincipalPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:     jsii.String("PermitParamGet"),
-		Effect:  awsiam.Effect_ALLOW,
-		Actions: jsii.Strings("ssm:GetParametersByPath"),
-		Resources: &[]*string{
-			awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:ssm:eu-west-1:${AWS::AccountId}:parameter/%s/%s/%s/*", props.Tenant, props.Environment, props.Appplication)), nil),
-		},
-	}))
+	stage := defaultStage(httpapi)
 
-	//
-	httpapi := awsapigatewayv2.NewHttpApi(construct, jsii.String("ApplicationAPI"), &awsapigatewayv2.HttpApiProps{})
+	accessLogs := AccessLogStack(construct, "AccessLogs", stage, props.Tenant)
+
+	lambdas := map[string]awslambda.IFunction{}
+
+	for _, command := range commands {
+
+		log.Printf("Adding function %s from %s\n", command.Manifest.Name, path.Join(command.ModuleDir, command.Package))
+
+		functionProps := &functions.FunctionProps{
+			Command:   command,
+			BuildInfo: buildInfo,
+			Variables: variables,
+			HttpApi:   httpapi,
+		}
+
+		network.Attach(functionProps)
+
+		function := functions.GoFunction(construct, functions.ConstructID(command.Manifest.Name), functionProps)
+
+		function.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:     jsii.String("PermitParamGet"),
+			Effect:  awsiam.Effect_ALLOW,
+			Actions: jsii.Strings("ssm:GetParametersByPath"),
+			Resources: &[]*string{
+				awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:ssm:eu-west-1:${AWS::AccountId}:parameter%s", naming.ParameterPath())), nil),
+				awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:ssm:eu-west-1:${AWS::AccountId}:parameter%s/*", naming.ParameterPath())), nil),
+			},
+		}))
+
+		if table != nil {
+			storage.GrantReadWriteItems(table, function)
+		}
+
+		// every stage, the previous version stays valid during a rotation
+		for _, name := range secretNames {
+			applicationSecrets[name].GrantRead(function, nil)
+		}
+
+		lambdas[command.Manifest.Name] = function
+	}
+
+	// the rotation function of the created secrets
+	rotationProps := &functions.FunctionProps{BuildInfo: buildInfo}
+	network.Attach(rotationProps)
+	secrets.AddRotations(construct, "Rotation", applicationSecrets, &props.Secrets, rotationProps)
+
+	applyThrottling(stage, commands, &props.Throttle)
 
-	// POST
-	apiIntegration := awsapigatewayv2integrations.NewLambdaProxyIntegration(&awsapigatewayv2integrations.LambdaProxyIntegrationProps{
-		Handler:              apiLambda,
-		PayloadFormatVersion: awsapigatewayv2.PayloadFormatVersion_VERSION_1_0(),
+	awscdk.NewCfnOutput(construct, jsii.String("ApiUrl"), &awscdk.CfnOutputProps{
+		Value: stageUrl(httpapi),
 	})
 
-	httpapi.AddRoutes(&awsapigatewayv2.AddRoutesOptions{
-		Integration: apiIntegration,
-		Path:        jsii.String("/version"),
-		Methods: &[]awsapigatewayv2.HttpMethod{
-			awsapigatewayv2.HttpMethod_GET,
-		},
+	awscdk.NewCfnOutput(construct, jsii.String("AccessLogGroup"), &awscdk.CfnOutputProps{
+		Value: accessLogs.Ref(),
 	})
 
-	httpapi.AddRoutes(&awsapigatewayv2.AddRoutesOptions{
-		Integration: apiIntegration,
-		Path:        jsii.String("/hello"),
-		Methods: &[]awsapigatewayv2.HttpMethod{
-			awsapigatewayv2.HttpMethod_GET,
-		},
+	// dashboards + alarms
+	observability.ObservabilityStack(construct, "Observability", &observability.ObservabilityProps{
+		Tenant:      props.Tenant,
+		Environment: props.Environment,
+		Functions:   lambdas,
+		HttpApi:     httpapi,
+		Alarms:      props.Alarms,
 	})
 
 	return construct
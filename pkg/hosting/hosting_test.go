@@ -0,0 +1,97 @@
+package hosting
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// synthAssets synthesizes the hosting construct into outdir, returning the
+// code S3 key of each Lambda function by logical ID
+func synthAssets(t *testing.T, outdir string) map[string]string {
+	t.Helper()
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Outdir: jsii.String(outdir),
+	})
+
+	stack := awscdk.NewStack(app, jsii.String("Test"), &awscdk.StackProps{
+		Synthesizer: awscdk.NewDefaultStackSynthesizer(&awscdk.DefaultStackSynthesizerProps{}),
+	})
+
+	HostingStack(stack, "Hosting", &HostingProps{
+		Tenant:       "tenant",
+		Environment:  "test",
+		Appplication: "app",
+	})
+
+	app.Synth(nil)
+
+	raw, err := ioutil.ReadFile(filepath.Join(outdir, "Test.template.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var template struct {
+		Resources map[string]struct {
+			Type       string `json:"Type"`
+			Properties struct {
+				Code struct {
+					S3Key string `json:"S3Key"`
+				} `json:"Code"`
+			} `json:"Properties"`
+		} `json:"Resources"`
+	}
+
+	if err := json.Unmarshal(raw, &template); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]string{}
+
+	for id, resource := range template.Resources {
+		if resource.Type == "AWS::Lambda::Function" {
+			keys[id] = resource.Properties.Code.S3Key
+		}
+	}
+
+	return keys
+}
+
+func TestReproducibleAssetHash(t *testing.T) {
+
+	if testing.Short() {
+		t.Skip("bundling the api is slow")
+	}
+
+	// entry and module paths are relative to the repository root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	first := synthAssets(t, t.TempDir())
+	second := synthAssets(t, t.TempDir())
+
+	if len(first) == 0 {
+		t.Fatal("no Lambda functions synthesized")
+	}
+
+	for id, key := range first {
+		if key == "" {
+			t.Errorf("%s: missing code S3Key", id)
+		}
+		if second[id] != key {
+			t.Errorf("%s: asset changed between synths, %s != %s", id, key, second[id])
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package hosting
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// defaultStage adds the $default stage in place of the L2 one, which does
+// not expose throttling or access logging. It keeps the construct path of
+// the L2 stage so the logical ID does not change.
+func defaultStage(httpapi awsapigatewayv2.HttpApi) awsapigatewayv2.CfnStage {
+
+	construct := awscdk.NewConstruct(httpapi, jsii.String("DefaultStage"))
+
+	return awsapigatewayv2.NewCfnStage(construct, jsii.String("Resource"), &awsapigatewayv2.CfnStageProps{
+		ApiId:      httpapi.ApiId(),
+		StageName:  jsii.String("$default"),
+		AutoDeploy: jsii.Bool(true),
+	})
+}
+
+// stageUrl is the invoke URL of the $default stage
+func stageUrl(httpapi awsapigatewayv2.HttpApi) *string {
+	return jsii.String(fmt.Sprintf("https://%s.execute-api.%s.%s/", *httpapi.ApiId(), *awscdk.Stack_Of(httpapi).Region(), *awscdk.Aws_URL_SUFFIX()))
+}
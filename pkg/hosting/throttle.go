@@ -0,0 +1,138 @@
+package hosting
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/functions"
+
+	"api/pkg/ratelimit"
+
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// ThrottleProps configures the $default stage throttling, 0 leaves the
+// account level limits in place
+type ThrottleProps struct {
+	Rate  float64 `envconfig:"RATE" default:"0"`
+	Burst float64 `envconfig:"BURST" default:"0"`
+	// per route limits e.g. "GET /notes:10/20" as rate/burst, these
+	// override the function manifests
+	Routes map[string]string `envconfig:"ROUTES"`
+}
+
+// routeThrottles collects the per route limits, keyed by route e.g. "GET /notes"
+func routeThrottles(commands []functions.Command, props *ThrottleProps) map[string]ratelimit.Quota {
+
+	routes := map[string]ratelimit.Quota{}
+	known := map[string]bool{}
+
+	for _, command := range commands {
+		for _, trigger := range command.Manifest.Triggers {
+
+			if trigger.HTTP == nil {
+				continue
+			}
+
+			methods := trigger.HTTP.Methods
+			if len(methods) == 0 {
+				methods = []string{"ANY"}
+			}
+
+			for _, method := range methods {
+				route := method + " " + trigger.HTTP.Path
+				known[route] = true
+
+				if trigger.HTTP.Throttle != nil {
+					quota, err := trigger.HTTP.Throttle.Quota()
+					if err != nil {
+						log.Fatalf("Cannot throttle route %q: %s", route, err)
+					}
+
+					routes[route] = quota
+				}
+			}
+		}
+	}
+
+	for route, limits := range props.Routes {
+
+		route = strings.TrimSpace(route)
+
+		// settings for a missing route fail the deployment
+		if !known[route] {
+			log.Fatalf("Cannot throttle route %q, no function serves it", route)
+		}
+
+		quota, err := ratelimit.ParseQuota(limits)
+		if err != nil {
+			log.Fatalf("Cannot parse throttle for route %q: %s", route, err)
+		}
+
+		routes[route] = quota
+	}
+
+	return routes
+}
+
+// routeSettings maps a quota onto the stage route settings
+func routeSettings(quota ratelimit.Quota) *awsapigatewayv2.CfnStage_RouteSettingsProperty {
+
+	settings := &awsapigatewayv2.CfnStage_RouteSettingsProperty{}
+
+	if quota.Rate != 0 {
+		settings.ThrottlingRateLimit = jsii.Number(quota.Rate)
+	}
+
+	if quota.Burst != 0 {
+		settings.ThrottlingBurstLimit = jsii.Number(float64(quota.Burst))
+	}
+
+	return settings
+}
+
+// applyThrottling sets the default and per route limits on the $default stage
+func applyThrottling(stage awsapigatewayv2.CfnStage, commands []functions.Command, props *ThrottleProps) {
+
+	routes := routeThrottles(commands, props)
+
+	if props.Rate == 0 && props.Burst == 0 && len(routes) == 0 {
+		return
+	}
+
+	defaults, err := functions.Throttle{Rate: props.Rate, Burst: props.Burst}.Quota()
+	if err != nil {
+		log.Fatal("Cannot throttle the API: ", err)
+	}
+
+	if defaults != (ratelimit.Quota{}) {
+		stage.SetDefaultRouteSettings(routeSettings(defaults))
+	}
+
+	if len(routes) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(routes))
+	for route := range routes {
+		keys = append(keys, route)
+	}
+	sort.Strings(keys)
+
+	// RouteSettings is plain JSON to CloudFormation, jsii would render the
+	// typed struct with lower case keys
+	settings := map[string]interface{}{}
+	for _, route := range keys {
+		log.Printf("Throttling route %s to %v/s burst %v\n", route, routes[route].Rate, routes[route].Burst)
+
+		setting := routeSettings(routes[route])
+		settings[route] = map[string]*float64{
+			"ThrottlingRateLimit":  setting.ThrottlingRateLimit,
+			"ThrottlingBurstLimit": setting.ThrottlingBurstLimit,
+		}
+	}
+
+	stage.SetRouteSettings(settings)
+}
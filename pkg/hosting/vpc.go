@@ -0,0 +1,197 @@
+package hosting
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/functions"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsec2"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+// VpcProps attaches the functions to an existing VPC, looked up by ID or
+// Name tag. The pipeline passes them on to the application synth, as the
+// boundary only grants the network services when enabled.
+type VpcProps struct {
+	Enabled bool   `envconfig:"ENABLED" default:"false"`
+	ID      string `envconfig:"ID"`
+	Name    string `envconfig:"NAME"`
+	// subnets the functions run in, private or isolated, a subnet group
+	// name e.g. Application overrides the type
+	SubnetType  string `envconfig:"SUBNET_TYPE" default:"private"`
+	SubnetGroup string `envconfig:"SUBNET_GROUP"`
+	// endpoints for SSM, Secrets Manager and DynamoDB, only for a VPC of the
+	// tenant's own. Private DNS allows one interface endpoint per service in
+	// a VPC, so a shared VPC provisions them once, outside the tenant stacks.
+	Endpoints bool `envconfig:"ENDPOINTS" default:"false"`
+}
+
+// Environment passes the settings on as the CDK_VPC_* variables
+func (p VpcProps) Environment() map[string]string {
+
+	if !p.Enabled {
+		return map[string]string{}
+	}
+
+	return map[string]string{
+		"CDK_VPC_ENABLED":      "true",
+		"CDK_VPC_ID":           p.ID,
+		"CDK_VPC_NAME":         p.Name,
+		"CDK_VPC_SUBNET_TYPE":  p.SubnetType,
+		"CDK_VPC_SUBNET_GROUP": p.SubnetGroup,
+		"CDK_VPC_ENDPOINTS":    strconv.FormatBool(p.Endpoints),
+	}
+}
+
+// Functions without internet access, private subnets reach it through NAT
+var subnetTypes = map[string]awsec2.SubnetType{
+	"private":  awsec2.SubnetType_PRIVATE,
+	"isolated": awsec2.SubnetType_ISOLATED,
+}
+
+// Network is the VPC attachment shared by the functions
+type Network struct {
+	Vpc           awsec2.IVpc
+	Subnets       *awsec2.SubnetSelection
+	SecurityGroup awsec2.SecurityGroup
+}
+
+// Attach places a function in the subnets behind the functions' security
+// group, a nil Network leaves it outside a VPC
+func (n *Network) Attach(props *functions.FunctionProps) {
+
+	if n == nil {
+		return
+	}
+
+	props.Vpc = n.Vpc
+	props.VpcSubnets = n.Subnets
+	props.SecurityGroups = []awsec2.ISecurityGroup{n.SecurityGroup}
+}
+
+// VpcStack looks up the VPC and creates the functions' security group and
+// the endpoints, nil when disabled
+func VpcStack(scope constructs.Construct, id string, props *VpcProps, tenant string) *Network {
+
+	if !props.Enabled {
+		return nil
+	}
+
+	construct := awscdk.NewConstruct(scope, &id)
+
+	lookup := &awsec2.VpcLookupOptions{}
+	switch {
+	case props.ID != "" && props.Name != "":
+		log.Fatal("Cannot look up the VPC by both ID and name, set CDK_VPC_ID or CDK_VPC_NAME")
+	case props.ID != "":
+		lookup.VpcId = jsii.String(props.ID)
+	case props.Name != "":
+		lookup.VpcName = jsii.String(props.Name)
+	default:
+		log.Fatal("No VPC to attach the functions to, set CDK_VPC_ID or CDK_VPC_NAME")
+	}
+
+	vpc := awsec2.Vpc_FromLookup(construct, jsii.String("Vpc"), lookup)
+
+	subnets, err := subnetSelection(props)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	securityGroup := awsec2.NewSecurityGroup(construct, jsii.String("FunctionSecurityGroup"), &awsec2.SecurityGroupProps{
+		Vpc:              vpc,
+		Description:      jsii.String("Application functions"),
+		AllowAllOutbound: jsii.Bool(true),
+	})
+
+	if props.Endpoints {
+		addEndpoints(construct, vpc, subnets, securityGroup, tenant)
+	}
+
+	return &Network{
+		Vpc:           vpc,
+		Subnets:       subnets,
+		SecurityGroup: securityGroup,
+	}
+}
+
+// subnetSelection selects the subnet group, or the subnets of the type
+func subnetSelection(props *VpcProps) (*awsec2.SubnetSelection, error) {
+
+	if props.SubnetGroup != "" {
+		return &awsec2.SubnetSelection{SubnetGroupName: jsii.String(props.SubnetGroup)}, nil
+	}
+
+	subnetType := strings.ToLower(props.SubnetType)
+	if subnetType == "" {
+		subnetType = "private"
+	}
+
+	typ, ok := subnetTypes[subnetType]
+	if !ok {
+		return nil, fmt.Errorf("cannot run functions in %q subnets, one of private or isolated", props.SubnetType)
+	}
+
+	return &awsec2.SubnetSelection{SubnetType: typ}, nil
+}
+
+// addEndpoints keeps the functions' AWS calls inside the VPC, the interface
+// endpoints only accept the functions' security group. The L2 endpoints
+// cannot be tagged in this CDK version, the boundary only lets the tenant
+// change the endpoints tagged with its name.
+func addEndpoints(construct awscdk.Construct, vpc awsec2.IVpc, subnets *awsec2.SubnetSelection, securityGroup awsec2.SecurityGroup, tenant string) {
+
+	tags := []map[string]string{{"Key": util.TenantTag, "Value": tenant}}
+	selected := vpc.SelectSubnets(subnets)
+
+	endpointSecurityGroup := awsec2.NewSecurityGroup(construct, jsii.String("EndpointSecurityGroup"), &awsec2.SecurityGroupProps{
+		Vpc:         vpc,
+		Description: jsii.String("Application endpoints"),
+	})
+	endpointSecurityGroup.AddIngressRule(securityGroup, awsec2.Port_Tcp(jsii.Number(443)), jsii.String("Application functions"), nil)
+
+	interfaces := []struct {
+		id      string
+		service awsec2.InterfaceVpcEndpointAwsService
+	}{
+		{"SsmEndpoint", awsec2.InterfaceVpcEndpointAwsService_SSM()},
+		{"SecretsManagerEndpoint", awsec2.InterfaceVpcEndpointAwsService_SECRETS_MANAGER()},
+	}
+
+	for _, i := range interfaces {
+		endpoint := awsec2.NewCfnVPCEndpoint(construct, jsii.String(i.id), &awsec2.CfnVPCEndpointProps{
+			VpcId:             vpc.VpcId(),
+			ServiceName:       i.service.Name(),
+			VpcEndpointType:   jsii.String("Interface"),
+			SubnetIds:         selected.SubnetIds,
+			SecurityGroupIds:  jsii.Strings(*endpointSecurityGroup.SecurityGroupId()),
+			PrivateDnsEnabled: jsii.Bool(true),
+		})
+		endpoint.AddPropertyOverride(jsii.String("Tags"), tags)
+	}
+
+	routeTables := []*string{}
+	seen := map[string]bool{}
+	for _, subnet := range *selected.Subnets {
+		id := subnet.RouteTable().RouteTableId()
+		if !seen[*id] {
+			seen[*id] = true
+			routeTables = append(routeTables, id)
+		}
+	}
+
+	endpoint := awsec2.NewCfnVPCEndpoint(construct, jsii.String("DynamoDbEndpoint"), &awsec2.CfnVPCEndpointProps{
+		VpcId:           vpc.VpcId(),
+		ServiceName:     awsec2.GatewayVpcEndpointAwsService_DYNAMODB().Name(),
+		VpcEndpointType: jsii.String("Gateway"),
+		RouteTableIds:   &routeTables,
+	})
+	endpoint.AddPropertyOverride(jsii.String("Tags"), tags)
+}
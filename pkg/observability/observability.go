@@ -0,0 +1,232 @@
+package observability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/functions"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/awssnssubscriptions"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+// AlarmProps configures the alarm thresholds, a threshold of 0 disables the alarm
+type AlarmProps struct {
+	Email             string  `envconfig:"EMAIL"`
+	ApiLatencyP99     float64 `envconfig:"API_LATENCY_P99" default:"1000"`
+	Api4xx            float64 `envconfig:"API_4XX" default:"50"`
+	Api5xx            float64 `envconfig:"API_5XX" default:"1"`
+	LambdaErrors      float64 `envconfig:"LAMBDA_ERRORS" default:"1"`
+	LambdaThrottles   float64 `envconfig:"LAMBDA_THROTTLES" default:"1"`
+	LambdaDurationP99 float64 `envconfig:"LAMBDA_DURATION_P99" default:"2500"`
+	LambdaConcurrency float64 `envconfig:"LAMBDA_CONCURRENCY" default:"100"`
+	EvaluationPeriods float64 `envconfig:"EVALUATION_PERIODS" default:"3"`
+}
+
+type ObservabilityProps struct {
+	Tenant      string                         ``
+	Environment string                         ``
+	Functions   map[string]awslambda.IFunction ``
+	HttpApi     awsapigatewayv2.HttpApi        ``
+	Alarms      AlarmProps                     ``
+}
+
+func ObservabilityStack(scope constructs.Construct, id string, props *ObservabilityProps) awscdk.Construct {
+
+	construct := awscdk.NewConstruct(scope, &id)
+
+	prefix := util.Naming{Tenant: props.Tenant, Environment: props.Environment}.Prefix()
+	// e.g. Acme-Staging in descriptions
+	label := strings.TrimSuffix(prefix, "-")
+
+	period := awscdk.Duration_Minutes(jsii.Number(1))
+
+	// alarm notifications
+	topic := awssns.NewTopic(construct, jsii.String("AlarmTopic"), &awssns.TopicProps{
+		DisplayName: jsii.String(fmt.Sprintf("%s application alarms", label)),
+	})
+
+	if props.Alarms.Email != "" {
+		topic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(props.Alarms.Email), nil))
+	}
+
+	awscdk.NewCfnOutput(construct, jsii.String("AlarmTopicArn"), &awscdk.CfnOutputProps{
+		Value: topic.TopicArn(),
+	})
+
+	// API metrics
+	apiLatency := props.HttpApi.MetricLatency(&awscloudwatch.MetricOptions{
+		Period:    period,
+		Statistic: jsii.String("p99"),
+	})
+
+	api4xx := props.HttpApi.MetricClientError(&awscloudwatch.MetricOptions{
+		Period:    period,
+		Statistic: jsii.String("Sum"),
+	})
+
+	api5xx := props.HttpApi.MetricServerError(&awscloudwatch.MetricOptions{
+		Period:    period,
+		Statistic: jsii.String("Sum"),
+	})
+
+	// Lambda metrics, one line per function
+	names := make([]string, 0, len(props.Functions))
+	for name := range props.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type lambdaMetrics struct {
+		name        string
+		errors      awscloudwatch.IMetric
+		throttles   awscloudwatch.IMetric
+		duration    awscloudwatch.IMetric
+		concurrency awscloudwatch.IMetric
+	}
+
+	var lambdas []lambdaMetrics
+	var lambdaErrors, lambdaThrottles, lambdaDuration, lambdaConcurrency []awscloudwatch.IMetric
+
+	for _, name := range names {
+		function := props.Functions[name]
+
+		m := lambdaMetrics{
+			name: name,
+			errors: function.MetricErrors(&awscloudwatch.MetricOptions{
+				Label:     jsii.String(name),
+				Period:    period,
+				Statistic: jsii.String("Sum"),
+			}),
+			throttles: function.MetricThrottles(&awscloudwatch.MetricOptions{
+				Label:     jsii.String(name),
+				Period:    period,
+				Statistic: jsii.String("Sum"),
+			}),
+			duration: function.MetricDuration(&awscloudwatch.MetricOptions{
+				Label:     jsii.String(name),
+				Period:    period,
+				Statistic: jsii.String("p99"),
+			}),
+			concurrency: function.Metric(jsii.String("ConcurrentExecutions"), &awscloudwatch.MetricOptions{
+				Label:     jsii.String(name),
+				Period:    period,
+				Statistic: jsii.String("Maximum"),
+			}),
+		}
+
+		lambdas = append(lambdas, m)
+
+		lambdaErrors = append(lambdaErrors, m.errors)
+		lambdaThrottles = append(lambdaThrottles, m.throttles)
+		lambdaDuration = append(lambdaDuration, m.duration)
+		lambdaConcurrency = append(lambdaConcurrency, m.concurrency)
+	}
+
+	awscloudwatch.NewDashboard(construct, jsii.String("Dashboard"), &awscloudwatch.DashboardProps{
+		DashboardName: jsii.String(fmt.Sprintf("%sApplication", prefix)),
+		Widgets: &[]*[]awscloudwatch.IWidget{
+			{
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("API Latency (p99)"),
+					Left:  &[]awscloudwatch.IMetric{apiLatency},
+					Width: jsii.Number(8),
+				}),
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("API 4xx"),
+					Left:  &[]awscloudwatch.IMetric{api4xx},
+					Width: jsii.Number(8),
+				}),
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("API 5xx"),
+					Left:  &[]awscloudwatch.IMetric{api5xx},
+					Width: jsii.Number(8),
+				}),
+			},
+			{
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("Lambda Errors"),
+					Left:  &lambdaErrors,
+					Width: jsii.Number(6),
+				}),
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("Lambda Throttles"),
+					Left:  &lambdaThrottles,
+					Width: jsii.Number(6),
+				}),
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("Lambda Duration (p99)"),
+					Left:  &lambdaDuration,
+					Width: jsii.Number(6),
+				}),
+				awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+					Title: jsii.String("Lambda Concurrency"),
+					Left:  &lambdaConcurrency,
+					Width: jsii.Number(6),
+				}),
+			},
+		},
+	})
+
+	alarmAction := awscloudwatchactions.NewSnsAction(topic)
+
+	type alarmSpec struct {
+		id          string
+		description string
+		metric      awscloudwatch.IMetric
+		threshold   float64
+	}
+
+	alarms := []alarmSpec{
+		{"ApiLatencyAlarm", "API p99 latency (ms)", apiLatency, props.Alarms.ApiLatencyP99},
+		{"Api4xxAlarm", "API 4xx responses", api4xx, props.Alarms.Api4xx},
+		{"Api5xxAlarm", "API 5xx responses", api5xx, props.Alarms.Api5xx},
+	}
+
+	// per function alarms
+	for _, m := range lambdas {
+		id := functions.ConstructID(m.name)
+
+		alarms = append(alarms,
+			alarmSpec{id + "LambdaErrorsAlarm", m.name + " Lambda invocation errors", m.errors, props.Alarms.LambdaErrors},
+			alarmSpec{id + "LambdaThrottlesAlarm", m.name + " Lambda throttled invocations", m.throttles, props.Alarms.LambdaThrottles},
+			alarmSpec{id + "LambdaDurationAlarm", m.name + " Lambda p99 duration (ms)", m.duration, props.Alarms.LambdaDurationP99},
+			alarmSpec{id + "LambdaConcurrencyAlarm", m.name + " Lambda concurrent executions", m.concurrency, props.Alarms.LambdaConcurrency},
+		)
+	}
+
+	evaluationPeriods := props.Alarms.EvaluationPeriods
+	if evaluationPeriods == 0 {
+		evaluationPeriods = 1
+	}
+
+	for _, a := range alarms {
+		if a.threshold == 0 {
+			continue
+		}
+
+		alarm := awscloudwatch.NewAlarm(construct, jsii.String(a.id), &awscloudwatch.AlarmProps{
+			Metric:             a.metric,
+			AlarmDescription:   jsii.String(fmt.Sprintf("%s %s", label, a.description)),
+			Threshold:          jsii.Number(a.threshold),
+			EvaluationPeriods:  jsii.Number(evaluationPeriods),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+			TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+		})
+
+		alarm.AddAlarmAction(alarmAction)
+		alarm.AddOkAction(alarmAction)
+	}
+
+	return construct
+}
@@ -0,0 +1,141 @@
+package observability
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/awslambda"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var alarmProps = AlarmProps{
+	ApiLatencyP99:     1000,
+	Api4xx:            50,
+	Api5xx:            1,
+	LambdaErrors:      1,
+	LambdaThrottles:   1,
+	LambdaDurationP99: 2500,
+	LambdaConcurrency: 100,
+	EvaluationPeriods: 3,
+}
+
+func observabilityTemplate(t *testing.T, alarms AlarmProps) assertions.Template {
+	t.Helper()
+
+	stack := awscdk.NewStack(awscdk.NewApp(nil), jsii.String("Test"), nil)
+
+	function := awslambda.NewFunction(stack, jsii.String("Notes"), &awslambda.FunctionProps{
+		Runtime: awslambda.Runtime_NODEJS_14_X(),
+		Handler: jsii.String("index.handler"),
+		Code:    awslambda.Code_FromInline(jsii.String("exports.handler = async () => {}")),
+	})
+
+	ObservabilityStack(stack, "Observability", &ObservabilityProps{
+		Tenant:      "acme",
+		Environment: "test",
+		Functions:   map[string]awslambda.IFunction{"notes": function},
+		HttpApi:     awsapigatewayv2.NewHttpApi(stack, jsii.String("Api"), nil),
+		Alarms:      alarms,
+	})
+
+	return assertions.Template_FromStack(stack)
+}
+
+// alarms returns the alarms by the start of their logical ID
+func alarms(t *testing.T, template assertions.Template) map[string]map[string]interface{} {
+	t.Helper()
+
+	byID := map[string]map[string]interface{}{}
+	for id, alarm := range *template.FindResources(jsii.String("AWS::CloudWatch::Alarm"), nil) {
+		id = strings.TrimPrefix(id, "Observability")
+		byID[id[:strings.Index(id, "Alarm")+len("Alarm")]] = (*alarm)["Properties"].(map[string]interface{})
+	}
+	return byID
+}
+
+func ids(alarms map[string]map[string]interface{}) []string {
+	var ids []string
+	for id := range alarms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestAlarms(t *testing.T) {
+
+	template := observabilityTemplate(t, alarmProps)
+	created := alarms(t, template)
+
+	want := []string{
+		"Api4xxAlarm", "Api5xxAlarm", "ApiLatencyAlarm",
+		"NotesLambdaConcurrencyAlarm", "NotesLambdaDurationAlarm", "NotesLambdaErrorsAlarm", "NotesLambdaThrottlesAlarm",
+	}
+	if got := ids(created); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("alarms are %v, want %v", got, want)
+	}
+
+	topics := *template.FindResources(jsii.String("AWS::SNS::Topic"), nil)
+	if len(topics) != 1 {
+		t.Fatalf("%d topics, want 1", len(topics))
+	}
+	var topic string
+	for id := range topics {
+		topic = id
+	}
+
+	// every alarm notifies the topic when raised and when cleared
+	notify := `[{"Ref":"` + topic + `"}]`
+	for id, alarm := range created {
+		for _, actions := range []string{"AlarmActions", "OKActions"} {
+			raw, err := json.Marshal(alarm[actions])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != notify {
+				t.Errorf("%s: %s are %s, want %s", id, actions, raw, notify)
+			}
+		}
+		if !strings.HasPrefix(alarm["AlarmDescription"].(string), "Acme-Test ") {
+			t.Errorf("%s: description is %s", id, alarm["AlarmDescription"])
+		}
+	}
+
+	if got := created["ApiLatencyAlarm"]; got["Threshold"] != 1000.0 || got["EvaluationPeriods"] != 3.0 || got["ExtendedStatistic"] != "p99" {
+		t.Errorf("ApiLatencyAlarm is %v", got)
+	}
+}
+
+func TestAlarmsDisabled(t *testing.T) {
+
+	// a zero threshold disables its alarm
+	props := alarmProps
+	props.Api4xx = 0
+	props.LambdaThrottles = 0
+
+	created := alarms(t, observabilityTemplate(t, props))
+
+	for _, id := range []string{"Api4xxAlarm", "NotesLambdaThrottlesAlarm"} {
+		if _, ok := created[id]; ok {
+			t.Errorf("%s created with a zero threshold", id)
+		}
+	}
+	if len(created) != 5 {
+		t.Errorf("alarms are %v", ids(created))
+	}
+}
+
+func TestDashboard(t *testing.T) {
+
+	template := observabilityTemplate(t, alarmProps)
+
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Dashboard"), jsii.Number(1))
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Dashboard"), map[string]interface{}{
+		"DashboardName": "Acme-Test-Application",
+	})
+}
@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/functions"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awssecretsmanager"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+// SecretsProps declares the application secrets by name, every function may
+// read them and finds their ARNs in SECRET_<NAME>_ARN, see secrets.EnvVar in
+// resources/api
+type SecretsProps struct {
+	// secrets created as <tenant>/<environment>/<name> e.g. db,stripe
+	Create []string `envconfig:"CREATE"`
+	// existing secrets by full name e.g. github:acme/staging/github-token,
+	// under the tenant's path as the boundary only covers those
+	Import map[string]string `envconfig:"IMPORT"`
+	// rotation of created secrets every days e.g. db:30, by a rotation
+	// function the stack adds
+	Rotate map[string]string `envconfig:"ROTATE"`
+}
+
+// SecretsStack creates and imports the declared secrets, by name
+func SecretsStack(scope constructs.Construct, id string, props *SecretsProps, naming util.Naming) map[string]awssecretsmanager.ISecret {
+
+	construct := awscdk.NewConstruct(scope, &id)
+
+	secrets := map[string]awssecretsmanager.ISecret{}
+
+	for _, name := range props.Create {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := secrets[name]; ok {
+			log.Fatalf("Secret %q is declared twice", name)
+		}
+
+		log.Printf("Creating secret %s%s\n", naming.PathPrefix(), name)
+
+		secrets[name] = awssecretsmanager.NewSecret(construct, jsii.String(functions.ConstructID(name)), &awssecretsmanager.SecretProps{
+			SecretName: jsii.String(naming.PathPrefix() + name),
+			// a generated password until the application sets a value
+			GenerateSecretString: &awssecretsmanager.SecretStringGenerator{
+				ExcludePunctuation: jsii.Bool(true),
+			},
+			// a secret outlives the stack, import it to redeploy
+			RemovalPolicy: awscdk.RemovalPolicy_RETAIN,
+		})
+	}
+
+	for name, secretName := range props.Import {
+		name = strings.TrimSpace(name)
+		if _, ok := secrets[name]; ok {
+			log.Fatalf("Secret %q is declared twice", name)
+		}
+
+		// the boundary grants secretsmanager only on the tenant's path
+		if !strings.HasPrefix(secretName, naming.PathPrefix()) {
+			log.Fatalf("Cannot import secret %q as %s, it is outside %s", secretName, name, naming.PathPrefix())
+		}
+
+		log.Printf("Importing secret %s\n", secretName)
+
+		secrets[name] = awssecretsmanager.Secret_FromSecretNameV2(construct, jsii.String(functions.ConstructID(name)), jsii.String(secretName))
+	}
+
+	return secrets
+}
+
+// rotationCommand implements the Secrets Manager rotation steps for the
+// generated secrets, it has no manifest as it is not an application function
+var rotationCommand = functions.Command{
+	ModuleDir: filepath.Join("resources", "api"),
+	Package:   "./cmd/rotate",
+	Manifest:  functions.Manifest{Name: "rotate", Memory: 128, Timeout: 30},
+}
+
+// parseRotation reads the days between rotations e.g. 30
+func parseRotation(rotation string) (float64, error) {
+
+	days, err := strconv.ParseUint(strings.TrimSpace(rotation), 10, 16)
+	if err != nil || days < 1 || days > 365 {
+		return 0, fmt.Errorf("invalid days %q, rotate every 1 to 365 days", rotation)
+	}
+
+	return float64(days), nil
+}
+
+// AddRotations schedules the rotation of created secrets by a rotation
+// function of their own, built with props. Imported secrets rotate where
+// they are managed.
+func AddRotations(scope constructs.Construct, id string, secrets map[string]awssecretsmanager.ISecret, props *SecretsProps, function *functions.FunctionProps) {
+
+	if len(props.Rotate) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(props.Rotate))
+	for name := range props.Rotate {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	functionProps := *function
+	functionProps.Command = rotationCommand
+	rotation := functions.GoFunction(scope, id, &functionProps)
+
+	imported := map[string]bool{}
+	for name := range props.Import {
+		imported[strings.TrimSpace(name)] = true
+	}
+
+	for _, key := range names {
+		name := strings.TrimSpace(key)
+
+		secret, ok := secrets[name]
+		if !ok || imported[name] {
+			log.Fatalf("Cannot rotate secret %q, it is not created by the stack", name)
+		}
+
+		days, err := parseRotation(props.Rotate[key])
+		if err != nil {
+			log.Fatalf("Cannot parse rotation for secret %q: %s", name, err)
+		}
+
+		log.Printf("Rotating secret %s every %v days\n", name, days)
+
+		// grants the function the rotation steps on the secret
+		secret.AddRotationSchedule(jsii.String("Rotation"), &awssecretsmanager.RotationScheduleOptions{
+			RotationLambda:     rotation,
+			AutomaticallyAfter: awscdk.Duration_Days(jsii.Number(days)),
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package secrets
+
+import "testing"
+
+func TestParseRotation(t *testing.T) {
+
+	if days, err := parseRotation("30"); err != nil || days != 30 {
+		t.Errorf("30: %v, %v", days, err)
+	}
+
+	for _, invalid := range []string{"", "rotate/30", "0", "400", "x"} {
+		if _, err := parseRotation(invalid); err == nil {
+			t.Errorf("%s: no error", invalid)
+		}
+	}
+}
@@ -2,7 +2,15 @@ package stacks
 
 import (
 	"fmt"
+	"log"
+	"permission-boundary-pipeline-cdk/pkg/buildinfo"
 	"permission-boundary-pipeline-cdk/pkg/hosting"
+	"permission-boundary-pipeline-cdk/pkg/observability"
+	"permission-boundary-pipeline-cdk/pkg/secrets"
+	"permission-boundary-pipeline-cdk/pkg/storage"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"api/pkg/cors"
 
 	"github.com/aws/aws-cdk-go/awscdk"
 	"github.com/aws/aws-cdk-go/awscdk/awsiam"
@@ -11,11 +19,22 @@ import (
 )
 
 type ApplicationProps struct {
-	Tenant      string            `envconfig:"TENANT" default:"openenterprise"`
-	Environment string            `envconfig:"ENVIRONMENT" default:"staging"`
-	Application string            `envconfig:"APPLICATION" default:"superapp4000"`
-	Qualifier   string            `envconfig:"QUALIFIER" default:"hnb659fds"`
-	StackProps  awscdk.StackProps ``
+	Tenant      string `envconfig:"TENANT" default:"openenterprise"`
+	Environment string `envconfig:"ENVIRONMENT" default:"staging"`
+	Application string `envconfig:"APPLICATION" default:"superapp4000"`
+	Qualifier   string `envconfig:"QUALIFIER" default:"hnb659fds"`
+	// tagged on every resource, Version is the build version when empty
+	CostCentre string                   `envconfig:"COST_CENTRE"`
+	Version    string                   `envconfig:"VERSION"`
+	Alarms     observability.AlarmProps `envconfig:"ALARM"`
+	Table      storage.TableProps       `envconfig:"TABLE"`
+	Secrets    secrets.SecretsProps     `envconfig:"SECRETS"`
+	Vpc        hosting.VpcProps         `envconfig:"VPC"`
+	Throttle   hosting.ThrottleProps    `envconfig:"THROTTLE"`
+	Cors       cors.Policy              `envconfig:"CORS"`
+	// the functions' commands, resources/ when empty
+	ResourcesDir string            `ignored:"true"`
+	StackProps   awscdk.StackProps ``
 }
 
 func ApplicationStack(scope constructs.Construct, id string, props *ApplicationProps) awscdk.Stack {
@@ -24,20 +43,74 @@ func ApplicationStack(scope constructs.Construct, id string, props *ApplicationP
 		sprops = props.StackProps
 	}
 
+	naming := applicationNaming(props)
+	if err := naming.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// the boundary only covers names starting with the naming prefix, which
+	// CloudFormation generated names take from the stack name
+	if sprops.StackName != nil && *sprops.StackName != naming.ApplicationStackName() {
+		log.Fatalf("Application stack must be named %s, not %s", naming.ApplicationStackName(), *sprops.StackName)
+	}
+	sprops.StackName = jsii.String(naming.ApplicationStackName())
+
 	stack := awscdk.NewStack(scope, &id, &sprops)
 
+	// the boundary denies resources tagged for another tenant and creating
+	// roles and functions without the tenant tag
+	tags := mandatoryTags(naming, props.CostCentre, applicationVersion(props))
+	if err := applyTags(stack, tags); err != nil {
+		log.Fatal(err)
+	}
+	awscdk.Aspects_Of(stack).Add(&conventions{naming: naming, tags: tags})
+
 	hosting.HostingStack(stack, "Hosting", &hosting.HostingProps{
-		Tenant:      props.Tenant,
-		Environment: props.Environment,
+		Tenant:       props.Tenant,
+		Environment:  props.Environment,
+		Appplication: props.Application,
+		Alarms:       props.Alarms,
+		Table:        props.Table,
+		Secrets:      props.Secrets,
+		Vpc:          props.Vpc,
+		Throttle:     props.Throttle,
+		Cors:         props.Cors,
+		ResourcesDir: props.ResourcesDir,
 	})
 
 	// apply boundary to all roles within the stack
-	boundary := awsiam.ManagedPolicy_FromManagedPolicyArn(
-		stack,
-		jsii.String("Boundary"),
-		jsii.String(fmt.Sprintf("arn:aws:iam::%s:policy/%s-permissions-boundary-%s", *awscdk.Aws_ACCOUNT_ID(), props.Qualifier, *awscdk.Aws_ACCOUNT_ID())),
-	)
+	boundary := &boundaryPolicy{
+		arn: fmt.Sprintf("arn:aws:iam::%s:policy/%s-permissions-boundary-%s", *awscdk.Aws_ACCOUNT_ID(), props.Qualifier, *awscdk.Aws_ACCOUNT_ID()),
+	}
 	awsiam.PermissionsBoundary_Of(stack).Apply(boundary)
 
 	return stack
 }
+
+// boundaryPolicy refers to the permissions boundary by ARN. An imported
+// policy would add a construct the conventions aspect cannot be handed.
+type boundaryPolicy struct {
+	arn string
+}
+
+// ManagedPolicyArn implements awsiam.IManagedPolicy
+func (b *boundaryPolicy) ManagedPolicyArn() *string {
+	return jsii.String(b.arn)
+}
+
+func applicationNaming(props *ApplicationProps) util.Naming {
+	return util.Naming{
+		Tenant:      props.Tenant,
+		Environment: props.Environment,
+		Application: props.Application,
+		Qualifier:   props.Qualifier,
+	}
+}
+
+// applicationVersion is the Version tag, the api build version by default
+func applicationVersion(props *ApplicationProps) string {
+	if props.Version != "" {
+		return props.Version
+	}
+	return buildinfo.FromEnv(props.Tenant, props.Environment).Version
+}
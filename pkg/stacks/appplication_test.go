@@ -0,0 +1,295 @@
+package stacks
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"permission-boundary-pipeline-cdk/pkg/hosting"
+	"permission-boundary-pipeline-cdk/pkg/secrets"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+const testQualifier = "testqual"
+
+var testNaming = util.Naming{Tenant: "acme", Environment: "test", Application: "app", Qualifier: testQualifier}
+
+var testTags = mandatoryTags(testNaming, "cc-1234", "1.0.0")
+
+func applicationTemplate(t *testing.T) assertions.Template {
+	t.Helper()
+
+	props := applicationProps()
+
+	return assertions.Template_FromStack(ApplicationStack(newApp(t), "Application", &props))
+}
+
+func applicationProps() ApplicationProps {
+	return ApplicationProps{
+		Tenant:      "acme",
+		Environment: "test",
+		Application: "app",
+		Qualifier:   testQualifier,
+		CostCentre:  "cc-1234",
+		Version:     "1.0.0",
+		StackProps: awscdk.StackProps{
+			Env: &awscdk.Environment{
+				Account: jsii.String("123456789012"),
+				Region:  jsii.String("eu-west-1"),
+			},
+			// as cmd/application, assets go to the qualifier's bootstrap
+			Synthesizer: awscdk.NewDefaultStackSynthesizer(&awscdk.DefaultStackSynthesizerProps{
+				Qualifier: jsii.String(testQualifier),
+			}),
+		},
+	}
+}
+
+func TestApplicationRoleBoundary(t *testing.T) {
+
+	template := applicationTemplate(t)
+
+	boundary := map[string]interface{}{
+		"Fn::Join": []interface{}{"", []interface{}{
+			"arn:aws:iam::",
+			map[string]interface{}{"Ref": "AWS::AccountId"},
+			":policy/" + testQualifier + "-permissions-boundary-",
+			map[string]interface{}{"Ref": "AWS::AccountId"},
+		}},
+	}
+
+	roles := resources(template, "AWS::IAM::Role")
+	if len(roles) == 0 {
+		t.Fatal("no roles in the application stack")
+	}
+
+	want := asJSON(t, boundary)
+
+	for id, role := range roles {
+		properties, _ := role["Properties"].(map[string]interface{})
+		if got := asJSON(t, properties["PermissionsBoundary"]); got != want {
+			t.Errorf("%s: PermissionsBoundary is %s, want %s", id, got, want)
+		}
+	}
+}
+
+func TestApplicationSnapshot(t *testing.T) {
+	snapshot(t, applicationTemplate(t), "application")
+}
+
+func TestApplicationTagging(t *testing.T) {
+
+	props := applicationProps()
+	stack := ApplicationStack(newApp(t), "Application", &props)
+	template := assertions.Template_FromStack(stack)
+
+	for _, err := range synthErrors(stack) {
+		t.Error(err)
+	}
+
+	// the boundary requires the tenant tag on creating a log group
+	for id, logGroup := range resources(template, "AWS::Logs::LogGroup") {
+		if tags := tagValues(logGroup["Properties"].(map[string]interface{})["Tags"]); tags["Tenant"] != "acme" {
+			t.Errorf("%s: Tenant tag is %q", id, tags["Tenant"])
+		}
+	}
+
+	for id, role := range resources(template, "AWS::IAM::Role") {
+		tags := tagValues(role["Properties"].(map[string]interface{})["Tags"])
+		for k, v := range testTags {
+			if tags[k] != v {
+				t.Errorf("%s: tag %s is %q, want %q", id, k, tags[k], v)
+			}
+		}
+	}
+}
+
+func TestApplicationSecrets(t *testing.T) {
+
+	props := applicationProps()
+	props.Secrets = secrets.SecretsProps{
+		Create: []string{"db"},
+		Import: map[string]string{"github": "acme/test/github-token"},
+		Rotate: map[string]string{"db": "30"},
+	}
+	stack := ApplicationStack(newApp(t), "Application", &props)
+	template := assertions.Template_FromStack(stack)
+
+	check(t, "created secret", func() {
+		template.HasResourceProperties(jsii.String("AWS::SecretsManager::Secret"), map[string]interface{}{
+			"Name": "acme/test/db",
+		})
+	})
+
+	check(t, "rotation", func() {
+		template.HasResourceProperties(jsii.String("AWS::SecretsManager::RotationSchedule"), map[string]interface{}{
+			"RotationRules": map[string]interface{}{"AutomaticallyAfterDays": 30},
+		})
+	})
+
+	// rotated by a function of its own, not an application function
+	for id, schedule := range resources(template, "AWS::SecretsManager::RotationSchedule") {
+		if lambda := asJSON(t, schedule["Properties"].(map[string]interface{})["RotationLambdaARN"]); !strings.Contains(lambda, `"HostingRotationLambda`) {
+			t.Errorf("%s: rotated by %s", id, lambda)
+		}
+	}
+
+	rotationGrants := ""
+	for id, policy := range resources(template, "AWS::IAM::Policy") {
+		if strings.HasPrefix(id, "HostingRotation") {
+			rotationGrants += asJSON(t, policy)
+		}
+	}
+	for _, grant := range []string{"secretsmanager:PutSecretValue", "secretsmanager:UpdateSecretVersionStage", "secretsmanager:GetRandomPassword"} {
+		if !strings.Contains(rotationGrants, grant) {
+			t.Errorf("no grant of %s to the rotation function", grant)
+		}
+	}
+
+	for _, function := range resources(template, "AWS::Lambda::Function") {
+		environment := asJSON(t, function["Properties"].(map[string]interface{})["Environment"])
+		if strings.Contains(environment, "LOG_LEVEL") && (!strings.Contains(environment, `"SECRET_DB_ARN":{"Ref":`) || !strings.Contains(environment, `"SECRET_GITHUB_ARN":{"Fn::Join"`)) {
+			t.Errorf("secret ARNs missing from %s", environment)
+		}
+	}
+
+	policies := ""
+	for _, policy := range resources(template, "AWS::IAM::Policy") {
+		policies += asJSON(t, policy)
+	}
+	for _, grant := range []string{"secretsmanager:GetSecretValue", ":secret:acme/test/github-token-??????"} {
+		if !strings.Contains(policies, grant) {
+			t.Errorf("no grant of %s", grant)
+		}
+	}
+
+	for _, err := range synthErrors(stack) {
+		t.Error(err)
+	}
+}
+
+func TestApplicationVpc(t *testing.T) {
+
+	props := applicationProps()
+	props.Vpc = hosting.VpcProps{Enabled: true, Name: "shared", Endpoints: true}
+	stack := ApplicationStack(newApp(t), "Application", &props)
+	template := assertions.Template_FromStack(stack)
+
+	functions := 0
+	for id, function := range resources(template, "AWS::Lambda::Function") {
+		if strings.HasPrefix(id, logRetentionProvider) {
+			continue
+		}
+		functions++
+		vpcConfig := asJSON(t, function["Properties"].(map[string]interface{})["VpcConfig"])
+		if !strings.Contains(vpcConfig, "SecurityGroupIds") || !strings.Contains(vpcConfig, "SubnetIds") {
+			t.Errorf("%s: not attached to the VPC, VpcConfig is %s", id, vpcConfig)
+		}
+	}
+	if functions == 0 {
+		t.Fatal("no functions in the application stack")
+	}
+
+	endpoints := map[string]string{}
+	for _, endpoint := range resources(template, "AWS::EC2::VPCEndpoint") {
+		properties := endpoint["Properties"].(map[string]interface{})
+		service := asJSON(t, properties["ServiceName"])
+		endpoints[service[strings.LastIndex(service, ".")+1:]] = properties["VpcEndpointType"].(string)
+
+		// the boundary only lets the tenant change its own endpoints
+		if tags := asJSON(t, properties["Tags"]); tags != `[{"Key":"Tenant","Value":"acme"}]` {
+			t.Errorf("%s: tags are %s", service, tags)
+		}
+	}
+	for service, typ := range map[string]string{`ssm"`: "Interface", `secretsmanager"`: "Interface", `dynamodb"]]}`: "Gateway"} {
+		if endpoints[service] != typ {
+			t.Errorf("%s endpoint is %q, want %s in %v", service, endpoints[service], typ, endpoints)
+		}
+	}
+
+	for _, err := range synthErrors(stack) {
+		t.Error(err)
+	}
+
+	// a shared VPC has its endpoints already
+	props = applicationProps()
+	props.Vpc = hosting.VpcProps{Enabled: true, Name: "shared"}
+	shared := assertions.Template_FromStack(ApplicationStack(newApp(t), "Application", &props))
+	if endpoints := resources(shared, "AWS::EC2::VPCEndpoint"); len(endpoints) != 0 {
+		t.Errorf("%d endpoints in a shared VPC", len(endpoints))
+	}
+}
+
+// the triggers bring resources of their own: an event source mapping, an
+// EventBridge rule and the CDK's bucket notifications handler
+func TestApplicationTriggers(t *testing.T) {
+
+	// testdata/triggers/<kind> holds a command with the one trigger
+	for kind, typ := range map[string]string{
+		"sqs":      "AWS::Lambda::EventSourceMapping",
+		"schedule": "AWS::Events::Rule",
+		"s3":       "Custom::S3BucketNotifications",
+	} {
+		kind, typ := kind, typ
+		t.Run(kind, func(t *testing.T) {
+
+			props := applicationProps()
+			props.ResourcesDir = filepath.Join(testdata, "triggers", kind)
+			stack := ApplicationStack(newApp(t), "Application", &props)
+			template := assertions.Template_FromStack(stack)
+
+			if found := resources(template, typ); len(found) != 1 {
+				t.Errorf("%d %s, want 1", len(found), typ)
+			}
+
+			for _, err := range synthErrors(stack) {
+				t.Error(err)
+			}
+		})
+	}
+
+	// the synth cannot check the handler's tags, the tag aspects still apply
+	props := applicationProps()
+	props.ResourcesDir = filepath.Join(testdata, "triggers", "s3")
+	template := assertions.Template_FromStack(ApplicationStack(newApp(t), "Application", &props))
+
+	handlers := 0
+	for id, function := range resources(template, "AWS::Lambda::Function") {
+		if !strings.HasPrefix(id, bucketNotificationsHandler) {
+			continue
+		}
+		handlers++
+		if tags := tagValues(function["Properties"].(map[string]interface{})["Tags"]); tags[util.TenantTag] != "acme" {
+			t.Errorf("%s: tags are %v", id, tags)
+		}
+	}
+	if handlers != 1 {
+		t.Errorf("%d bucket notifications handlers, want 1", handlers)
+	}
+}
+
+func TestApplicationThrottle(t *testing.T) {
+
+	props := applicationProps()
+	props.Throttle = hosting.ThrottleProps{Rate: 50, Burst: 100, Routes: map[string]string{"GET /hello": "5/10", "GET /ready": "5/0"}}
+	template := assertions.Template_FromStack(ApplicationStack(newApp(t), "Application", &props))
+
+	stages := resources(template, "AWS::ApiGatewayV2::Stage")
+	if len(stages) != 1 {
+		t.Fatalf("want one stage, got %d", len(stages))
+	}
+
+	for id, stage := range stages {
+		properties := stage["Properties"].(map[string]interface{})
+		if defaults := asJSON(t, properties["DefaultRouteSettings"]); defaults != `{"ThrottlingBurstLimit":100,"ThrottlingRateLimit":50}` {
+			t.Errorf("%s: DefaultRouteSettings is %s", id, defaults)
+		}
+		if routes := asJSON(t, properties["RouteSettings"]); routes != `{"GET /hello":{"ThrottlingBurstLimit":10,"ThrottlingRateLimit":5},"GET /ready":{"ThrottlingRateLimit":5}}` {
+			t.Errorf("%s: RouteSettings is %s", id, routes)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package stacks
+
+import (
+	"fmt"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/awskms"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// capability grants a service's actions on the tenant's resources
+type capability struct {
+	Sid     string
+	Actions []string
+	// ARN patterns of the actions' service, * where the service has no
+	// resource level permissions or its ARNs carry no name, the tenant tag
+	// guards then apply
+	Resources  []string
+	Conditions map[string]interface{}
+}
+
+// statement returns the capability as a policy statement, the region guard
+// restricts it to the allowed regions
+func (c capability) statement() awsiam.PolicyStatement {
+
+	props := &awsiam.PolicyStatementProps{
+		Sid:       jsii.String(c.Sid),
+		Effect:    awsiam.Effect_ALLOW,
+		Actions:   jsii.Strings(c.Actions...),
+		Resources: jsii.Strings(c.Resources...),
+	}
+
+	if c.Conditions != nil {
+		props.Conditions = &c.Conditions
+	}
+
+	return awsiam.NewPolicyStatement(props)
+}
+
+// capabilityStatements renders capabilities compactly, a managed policy is
+// limited to 6144 characters. Unconditional capabilities on all resources
+// share a statement, as do those on the ARNs of distinct services, since an
+// action never matches another service's ARN.
+func capabilityStatements(capabilities []capability) []awsiam.PolicyStatement {
+
+	unscoped := capability{Sid: "AllowUnscoped", Resources: []string{"*"}}
+	scoped := capability{Sid: "AllowTenantResources"}
+	services := map[string]bool{}
+	separate := []capability{}
+
+	for _, c := range capabilities {
+		switch {
+		case c.Conditions != nil:
+			separate = append(separate, c)
+
+		case len(c.Resources) == 1 && c.Resources[0] == "*":
+			unscoped.Actions = append(unscoped.Actions, c.Actions...)
+
+		case sharesService(services, c.Actions):
+			// e.g. read only access next to the service's full access
+			separate = append(separate, c)
+
+		default:
+			for _, action := range c.Actions {
+				services[strings.SplitN(action, ":", 2)[0]] = true
+			}
+			scoped.Actions = append(scoped.Actions, c.Actions...)
+			scoped.Resources = append(scoped.Resources, c.Resources...)
+		}
+	}
+
+	statements := []awsiam.PolicyStatement{}
+	for _, c := range append([]capability{unscoped, scoped}, separate...) {
+		if len(c.Actions) > 0 {
+			statements = append(statements, c.statement())
+		}
+	}
+
+	return statements
+}
+
+func sharesService(services map[string]bool, actions []string) bool {
+	for _, action := range actions {
+		if services[strings.SplitN(action, ":", 2)[0]] {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantCapabilities are the services application stacks use, scoped to the
+// names util.Naming generates for the tenant
+func tenantCapabilities(naming util.Naming, key awskms.IKey, vpc bool) []capability {
+
+	account := *awscdk.Aws_ACCOUNT_ID()
+	prefix := naming.Prefix()
+	bucket := naming.BucketPrefix()
+
+	arn := func(service, resource string) string {
+		return fmt.Sprintf("arn:aws:%s:*:%s:%s", service, account, resource)
+	}
+
+	capabilities := []capability{
+		{
+			// API ARNs carry an ID rather than a name, the API is created
+			// with the tenant tag and its routes, stages and integrations
+			// are authorized by the API's tags
+			Sid:       "AllowApiGatewayCreate",
+			Actions:   []string{"apigateway:POST"},
+			Resources: []string{"arn:aws:apigateway:*::/apis"},
+			Conditions: map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"aws:RequestTag/" + util.TenantTag: naming.Tenant,
+				},
+			},
+		},
+		{
+			Sid:       "AllowApiGateway",
+			Actions:   []string{"apigateway:*"},
+			Resources: []string{"arn:aws:apigateway:*::/apis/*", "arn:aws:apigateway:*::/tags/*"},
+			Conditions: map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"aws:ResourceTag/" + util.TenantTag: naming.Tenant,
+				},
+			},
+		},
+		{
+			Sid:     "AllowCloudWatchAlarms",
+			Actions: []string{"cloudwatch:*"},
+			Resources: []string{
+				arn("cloudwatch", "alarm:"+prefix+"*"),
+				fmt.Sprintf("arn:aws:cloudwatch::%s:dashboard/%s*", account, prefix),
+			},
+		},
+		{
+			Sid: "AllowCloudWatchMetrics",
+			Actions: []string{
+				"cloudwatch:DescribeAlarms",
+				"cloudwatch:GetMetricData",
+				"cloudwatch:GetMetricStatistics",
+				"cloudwatch:ListMetrics",
+				"cloudwatch:PutMetricData",
+			},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:     "AllowDynamoDB",
+			Actions: []string{"dynamodb:*"},
+			Resources: []string{
+				// with the table's indexes and streams
+				arn("dynamodb", "table/"+prefix+"*"),
+			},
+		},
+		{
+			Sid:       "AllowDynamoDBList",
+			Actions:   []string{"dynamodb:DescribeLimits", "dynamodb:ListStreams", "dynamodb:ListTables"},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:       "AllowEventRules",
+			Actions:   []string{"events:*"},
+			Resources: []string{arn("events", "rule/"+prefix+"*")},
+		},
+		{
+			Sid:       "AllowPutEvents",
+			Actions:   []string{"events:PutEvents"},
+			Resources: []string{arn("events", "event-bus/default")},
+		},
+		{
+			Sid:     "AllowLambda",
+			Actions: []string{"lambda:*"},
+			Resources: []string{
+				arn("lambda", "function:"+prefix+"*"),
+				arn("lambda", "layer:"+prefix+"*"),
+			},
+		},
+		{
+			Sid: "AllowLambdaEventSources",
+			Actions: []string{
+				"lambda:CreateEventSourceMapping",
+				"lambda:DeleteEventSourceMapping",
+				"lambda:UpdateEventSourceMapping",
+			},
+			Resources: []string{"*"},
+			Conditions: map[string]interface{}{
+				"ArnLike": map[string]interface{}{
+					"lambda:FunctionArn": arn("lambda", "function:"+prefix+"*"),
+				},
+			},
+		},
+		{
+			Sid: "AllowLambdaRead",
+			Actions: []string{
+				"lambda:GetAccountSettings",
+				"lambda:GetEventSourceMapping",
+				"lambda:ListEventSourceMappings",
+			},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:     "AllowLogs",
+			Actions: []string{"logs:*"},
+			Resources: []string{
+				arn("logs", "log-group:/aws/lambda/"+prefix+"*"),
+				arn("logs", "log-group:"+prefix+"*"),
+			},
+		},
+		{
+			// API Gateway access log delivery is set up account wide
+			Sid: "AllowLogDelivery",
+			Actions: []string{
+				"logs:CreateLogDelivery",
+				"logs:DeleteLogDelivery",
+				"logs:DescribeLogGroups",
+				"logs:DescribeResourcePolicies",
+				"logs:GetLogDelivery",
+				"logs:ListLogDeliveries",
+				"logs:PutResourcePolicy",
+				"logs:UpdateLogDelivery",
+			},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:     "AllowS3",
+			Actions: []string{"s3:*"},
+			// with the buckets' objects
+			Resources: []string{"arn:aws:s3:::" + bucket + "*"},
+		},
+		{
+			// function code is read from the bootstrap staging bucket
+			Sid:       "AllowStagingBucketRead",
+			Actions:   []string{"s3:GetObject*", "s3:GetBucket*", "s3:List*"},
+			Resources: []string{fmt.Sprintf("arn:aws:s3:::cdk-%s-assets-*", naming.Qualifier)},
+		},
+		{
+			Sid:       "AllowSecrets",
+			Actions:   []string{"secretsmanager:*"},
+			Resources: []string{arn("secretsmanager", "secret:"+naming.PathPrefix()+"*")},
+		},
+		{
+			Sid:       "AllowSecretsList",
+			Actions:   []string{"secretsmanager:GetRandomPassword", "secretsmanager:ListSecrets"},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:       "AllowSns",
+			Actions:   []string{"sns:*"},
+			Resources: []string{arn("sns", prefix+"*")},
+		},
+		{
+			Sid:       "AllowSqs",
+			Actions:   []string{"sqs:*"},
+			Resources: []string{arn("sqs", prefix+"*")},
+		},
+		{
+			Sid:     "AllowSsm",
+			Actions: []string{"ssm:*"},
+			Resources: []string{
+				arn("ssm", "parameter/"+naming.PathPrefix()+"*"),
+			},
+		},
+		{
+			// stacks check the bootstrap version on deploy
+			Sid:       "AllowBootstrapVersion",
+			Actions:   []string{"ssm:GetParameter", "ssm:GetParameters"},
+			Resources: []string{arn("ssm", fmt.Sprintf("parameter/cdk-bootstrap/%s/*", naming.Qualifier))},
+		},
+		{
+			Sid:       "AllowSsmDescribe",
+			Actions:   []string{"ssm:DescribeParameters"},
+			Resources: []string{"*"},
+		},
+		{
+			Sid:       "AllowXRay",
+			Actions:   []string{"xray:*"},
+			Resources: []string{"*"},
+		},
+	}
+
+	if vpc {
+		capabilities = append(capabilities, networkInterfaces)
+	}
+
+	return append(capabilities, keyCapabilities(key)...)
+}
+
+// deploymentCapabilities are tenantCapabilities plus the services only the
+// CloudFormation execution role uses
+func deploymentCapabilities(naming util.Naming, key awskms.IKey, vpc bool) []capability {
+
+	capabilities := tenantCapabilities(naming, key, vpc)
+
+	if vpc {
+		capabilities = append(capabilities, vpcCapabilities(naming)...)
+	}
+
+	return capabilities
+}
+
+// networkInterfaces are the functions' network interfaces in a VPC, and
+// CloudFormation's lookups
+var networkInterfaces = capability{
+	Sid: "AllowNetworkInterfaces",
+	Actions: []string{
+		"ec2:CreateNetworkInterface",
+		"ec2:DeleteNetworkInterface",
+		"ec2:Describe*",
+	},
+	Resources: []string{"*"},
+}
+
+// vpcCapabilities attach the functions to a VPC, see hosting.VpcProps. The
+// VPC is shared, so security groups and endpoints are created with the
+// tenant tag and only the tenant's are changed.
+func vpcCapabilities(naming util.Naming) []capability {
+
+	tenant := map[string]interface{}{"aws:RequestTag/" + util.TenantTag: naming.Tenant}
+
+	return []capability{
+		{
+			Sid:        "AllowVpcCreates",
+			Actions:    []string{"ec2:CreateSecurityGroup", "ec2:CreateVpcEndpoint"},
+			Resources:  []string{"*"},
+			Conditions: map[string]interface{}{"StringEquals": tenant},
+		},
+		{
+			// the tags of those creates only, later changes need the tenant tag
+			Sid:       "AllowVpcCreateTags",
+			Actions:   []string{"ec2:CreateTags"},
+			Resources: []string{"*"},
+			Conditions: map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"ec2:CreateAction": []string{"CreateSecurityGroup", "CreateVpcEndpoint"},
+				},
+			},
+		},
+		{
+			Sid: "AllowTenantVpcResources",
+			Actions: []string{
+				"ec2:*SecurityGroup*",
+				"ec2:*Tags",
+				"ec2:DeleteVpcEndpoints",
+				"ec2:ModifyVpcEndpoint",
+			},
+			Resources: []string{"*"},
+			Conditions: map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"aws:ResourceTag/" + util.TenantTag: naming.Tenant,
+				},
+			},
+		},
+	}
+}
+
+// regionGuard denies leaving the allowed regions, global services such as
+// IAM are requested in us-east-1
+func regionGuard() awsiam.PolicyStatement {
+	return awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:       jsii.String("DenyOtherRegions"),
+		Effect:    awsiam.Effect_DENY,
+		Actions:   jsii.Strings("*"),
+		Resources: jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"StringNotEquals": map[string]interface{}{"aws:RequestedRegion": allowedRegions},
+		},
+	})
+}
+
+// taggedCreates are the create calls CloudFormation sends the resource tags
+// with, ApplicationStack tags the resources they create
+var taggedCreates = []string{
+	"dynamodb:CreateTable",
+	"iam:CreateRole",
+	"lambda:CreateFunction",
+	"logs:CreateLogGroup",
+	"secretsmanager:CreateSecret",
+	"sns:CreateTopic",
+	"sqs:CreateQueue",
+}
+
+// tenantTagGuards deny acting on, or tagging resources for, another tenant
+// and creating resources without the tenant tag. Resources which cannot be
+// tagged are left to the name scoping.
+func tenantTagGuards(naming util.Naming) []awsiam.PolicyStatement {
+
+	guard := func(sid, key string) awsiam.PolicyStatement {
+		return awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:       jsii.String(sid),
+			Effect:    awsiam.Effect_DENY,
+			Actions:   jsii.Strings("*"),
+			Resources: jsii.Strings("*"),
+			Conditions: &map[string]interface{}{
+				"StringNotEquals": map[string]interface{}{key: naming.Tenant},
+				"Null":            map[string]interface{}{key: "false"},
+			},
+		})
+	}
+
+	return []awsiam.PolicyStatement{
+		guard("DenyOtherTenantResources", "aws:ResourceTag/"+util.TenantTag),
+		guard("DenyOtherTenantRequestTags", "aws:RequestTag/"+util.TenantTag),
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:     jsii.String("DenyUntaggedCreates"),
+			Effect:  awsiam.Effect_DENY,
+			Actions: jsii.Strings(taggedCreates...),
+			// the CDK log retention function cannot be tagged, nor can the
+			// function log groups Lambda and log retention create, and the
+			// bucket notifications handler is tagged where the synth cannot
+			// check it, the capabilities still limit them to the tenant's names
+			NotResources: jsii.Strings(
+				fmt.Sprintf("arn:aws:lambda:*:%s:function:%s-LogRetention*", *awscdk.Aws_ACCOUNT_ID(), naming.ApplicationStackName()),
+				fmt.Sprintf("arn:aws:lambda:*:%s:function:%s-BucketNotificationsHandler*", *awscdk.Aws_ACCOUNT_ID(), naming.ApplicationStackName()),
+				fmt.Sprintf("arn:aws:logs:*:*:log-group:/aws/lambda/%s*", naming.Prefix()),
+			),
+			Conditions: &map[string]interface{}{
+				"Null": map[string]interface{}{"aws:RequestTag/" + util.TenantTag: "true"},
+			},
+		}),
+	}
+}
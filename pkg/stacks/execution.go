@@ -0,0 +1,107 @@
+package stacks
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/awskms"
+	"github.com/aws/constructs-go/constructs/v3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// addExecutionPolicy creates the CloudFormation execution role's policy, in
+// place of AdministratorAccess. It grants the boundary's capabilities and
+// the management of the tenant's roles, so the role stays least privilege
+// should the boundary be detached.
+func addExecutionPolicy(stack constructs.Construct, props *PipelineStackProps, Qualifier string, key awskms.IKey) awsiam.ManagedPolicy {
+
+	naming := tenantNaming(props, Qualifier)
+	roles := fmt.Sprintf("arn:aws:iam::%s:role/%s*", *awscdk.Aws_ACCOUNT_ID(), naming.Prefix())
+
+	policy := awsiam.NewManagedPolicy(stack, jsii.String("ExecutionPolicy"), &awsiam.ManagedPolicyProps{
+		ManagedPolicyName: awscdk.Fn_Sub(jsii.String(fmt.Sprintf("%s-cfn-exec-policy-${AWS::AccountId}", Qualifier)), nil),
+		Description:       jsii.String("Permissions of the CloudFormation execution role, limited to the tenant's resources."),
+	})
+
+	// Allow the services application stacks use, on the tenant's resources only.
+	policy.AddStatements(capabilityStatements(deploymentCapabilities(naming, key, props.Vpc.Enabled))...)
+
+	// Allow creating and changing the tenant's roles with the boundary applied.
+	policy.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:    jsii.String("AllowUpsertTenantRoles"),
+		Effect: awsiam.Effect_ALLOW,
+		Actions: jsii.Strings(
+			"iam:AttachRolePolicy",
+			"iam:CreateRole",
+			"iam:PutRolePermissionsBoundary",
+			"iam:PutRolePolicy",
+			"iam:UpdateAssumeRolePolicy",
+			"iam:UpdateRole",
+			"iam:UpdateRoleDescription",
+		),
+		Resources: jsii.Strings(roles),
+		Conditions: &map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"iam:PermissionsBoundary": boundaryArn(Qualifier),
+			},
+		},
+	}))
+
+	// Allow reading, tagging and deleting the tenant's roles.
+	policy.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:    jsii.String("AllowTenantRoles"),
+		Effect: awsiam.Effect_ALLOW,
+		Actions: jsii.Strings(
+			"iam:DeleteRole",
+			"iam:DeleteRolePolicy",
+			"iam:DetachRolePolicy",
+			"iam:GetRole",
+			"iam:GetRolePolicy",
+			"iam:ListAttachedRolePolicies",
+			"iam:ListRolePolicies",
+			"iam:TagRole",
+			"iam:UntagRole",
+		),
+		Resources: jsii.Strings(roles),
+	}))
+
+	// Allow passing roles that start with the application name to the listed services.
+	passRoles, err := passRoleStatements(naming, props.PassRoleServices)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy.AddStatements(passRoles...)
+
+	// Deny leaving the allowed regions.
+	policy.AddStatements(regionGuard())
+
+	return policy
+}
+
+// addExecutionGuardrails creates the second policy of the CloudFormation
+// execution role, the application boundary's tenant tag guards and
+// guardrails. The deployment boundary leaves them out to fit the size limit,
+// and the grants leave them no room in the execution policy.
+func addExecutionGuardrails(stack constructs.Construct, props *PipelineStackProps, Qualifier string) awsiam.ManagedPolicy {
+
+	naming := tenantNaming(props, Qualifier)
+
+	policy := awsiam.NewManagedPolicy(stack, jsii.String("ExecutionGuardrails"), &awsiam.ManagedPolicyProps{
+		ManagedPolicyName: awscdk.Fn_Sub(jsii.String(fmt.Sprintf("%s-cfn-exec-guardrails-${AWS::AccountId}", Qualifier)), nil),
+		Description:       jsii.String("Guardrails of the CloudFormation execution role."),
+	})
+
+	// Deny reaching resources tagged for another tenant.
+	policy.AddStatements(tenantTagGuards(naming)...)
+
+	// Deny privilege escalation paths.
+	guardrails, err := guardrailStatements(naming, props.DisabledGuardrails)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy.AddStatements(guardrails...)
+
+	return policy
+}
@@ -0,0 +1,77 @@
+package stacks
+
+import (
+	"strings"
+	"testing"
+
+	"permission-boundary-pipeline-cdk/pkg/util"
+)
+
+func TestExecutionPolicy(t *testing.T) {
+
+	template := pipelineTemplate(t)
+	qualifier := util.CalculateQualifier(pipelineProps.Tenant, pipelineProps.Application)
+
+	exec := resources(template, "AWS::IAM::Role")["CloudFormationExecutionRole"]
+	if exec == nil {
+		t.Fatal("CloudFormationExecutionRole: missing from the bootstrap")
+	}
+
+	// the bootstrap falls back to AdministratorAccess without execution policies
+	condition := asJSON(t, template.ToJSON())
+	if !strings.Contains(condition, `"HasCloudFormationExecutionPolicies":{"Fn::Not":[{"Fn::Equals":["",{"Fn::Join":["",[{"Ref":"ExecutionPolicy`) {
+		t.Error("HasCloudFormationExecutionPolicies: does not depend on the execution policy")
+	}
+	if policies := asJSON(t, exec["Properties"].(map[string]interface{})["ManagedPolicyArns"]); !strings.Contains(policies, `["HasCloudFormationExecutionPolicies",[{"Ref":"ExecutionPolicy`) || !strings.Contains(policies, `{"Ref":"ExecutionGuardrails`) {
+		t.Errorf("CloudFormationExecutionRole: policies are %s", policies)
+	}
+
+	for id, policy := range resources(template, "AWS::IAM::ManagedPolicy") {
+		if strings.HasPrefix(id, "ExecutionPolicy") {
+			if name := asJSON(t, policy["Properties"].(map[string]interface{})["ManagedPolicyName"]); !strings.Contains(name, qualifier+"-cfn-exec-policy-") {
+				t.Errorf("%s: name is %s", id, name)
+			}
+		}
+	}
+
+	boundary := boundaryStatements(template)
+	deployment := policyStatements(template, "DeploymentBoundary")
+	statements := policyStatements(template, "ExecutionPolicy")
+
+	// the same capabilities as the deployment boundary
+	for _, sid := range []string{"AllowUnscoped", "AllowTenantResources", "AllowLambdaEventSources", "AllowPassRoleToLambda", "DenyOtherRegions"} {
+		if got, want := asJSON(t, statements[sid]), asJSON(t, deployment[sid]); got != want {
+			t.Errorf("%s: is %s, the deployment boundary's %s", sid, got, want)
+		}
+	}
+
+	// the same guards and guardrails as the application boundary
+	guardrails := policyStatements(template, "ExecutionGuardrails")
+	for _, sid := range []string{"DenyOtherTenantResources", "DenyOtherTenantRequestTags", "DenyUntaggedCreates", "DenyEscalation", "DenyAdministratorAccess", "DenyAssumingForeignRoles"} {
+		if got, want := asJSON(t, guardrails[sid]), asJSON(t, boundary[sid]); got != want {
+			t.Errorf("%s: is %s, the boundary's %s", sid, got, want)
+		}
+	}
+
+	// roles are the tenant's only, created with the boundary
+	for _, sid := range []string{"AllowUpsertTenantRoles", "AllowTenantRoles"} {
+		if resource := strs(renderPolicy(statements[sid]["Resource"])); len(resource) != 1 || resource[0] != "arn:aws:iam::123456789012:role/Acme-Test-*" {
+			t.Errorf("%s: resources are %v", sid, resource)
+		}
+	}
+	if condition := asJSON(t, statements["AllowUpsertTenantRoles"]["Condition"]); !strings.Contains(condition, qualifier+"-permissions-boundary-") {
+		t.Errorf("AllowUpsertTenantRoles: condition is %s", condition)
+	}
+
+	// nothing the boundary guards against
+	for sid, statement := range statements {
+		if statement["Effect"] != "Allow" {
+			continue
+		}
+		for _, action := range strs(statement["Action"]) {
+			if action == "*" || strings.HasPrefix(action, "iam:*") || strings.HasPrefix(action, "sts:") {
+				t.Errorf("%s: allows %s", sid, action)
+			}
+		}
+	}
+}
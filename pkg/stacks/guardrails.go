@@ -0,0 +1,167 @@
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// guardrail explicitly denies a privilege escalation path, whatever else
+// the boundary or a role's own policies allow
+type guardrail struct {
+	// the name CDK_DISABLED_GUARDRAILS lists it by
+	Name         string
+	Sid          string
+	Actions      []string
+	Resources    []string
+	NotResources []string
+	Conditions   map[string]interface{}
+}
+
+func (g guardrail) statement() awsiam.PolicyStatement {
+
+	props := &awsiam.PolicyStatementProps{
+		Sid:     jsii.String(g.Sid),
+		Effect:  awsiam.Effect_DENY,
+		Actions: jsii.Strings(g.Actions...),
+	}
+
+	if len(g.NotResources) > 0 {
+		props.NotResources = jsii.Strings(g.NotResources...)
+	} else {
+		props.Resources = jsii.Strings(g.Resources...)
+	}
+
+	if g.Conditions != nil {
+		props.Conditions = &g.Conditions
+	}
+
+	return awsiam.NewPolicyStatement(props)
+}
+
+// tenantGuardrails are the guardrails of the tenant's boundary, all apply
+// unless disabled
+func tenantGuardrails(naming util.Naming) []guardrail {
+
+	account := *awscdk.Aws_ACCOUNT_ID()
+
+	return []guardrail{
+		{
+			// long lived credentials outside the pipeline, reads included
+			Name: "iam-users",
+			Sid:  "DenyIamUsers",
+			Actions: []string{
+				"iam:*AccessKey*",
+				"iam:*Group*",
+				"iam:*LoginProfile",
+				"iam:*ServiceSpecificCredential*",
+				"iam:*SSHPublicKey*",
+				"iam:*UserPolicy",
+				"iam:CreateUser",
+			},
+			Resources: []string{"*"},
+		},
+		{
+			Name:      "organizations",
+			Sid:       "DenyOrganizations",
+			Actions:   []string{"organizations:*"},
+			Resources: []string{"*"},
+		},
+		{
+			Name: "admin-access",
+			Sid:  "DenyAdministratorAccess",
+			Actions: []string{
+				"iam:AttachGroupPolicy",
+				"iam:AttachRolePolicy",
+				"iam:AttachUserPolicy",
+			},
+			Resources: []string{"*"},
+			Conditions: map[string]interface{}{
+				"ArnEquals": map[string]interface{}{
+					"iam:PolicyARN": "arn:aws:iam::aws:policy/AdministratorAccess",
+				},
+			},
+		},
+		{
+			// roles of other tenants, and of other accounts
+			Name: "foreign-roles",
+			Sid:  "DenyAssumingForeignRoles",
+			Actions: []string{
+				"sts:AssumeRole",
+				"sts:AssumeRoleWithSAML",
+				"sts:AssumeRoleWithWebIdentity",
+			},
+			NotResources: []string{
+				fmt.Sprintf("arn:aws:iam::%s:role/%s*", account, naming.Prefix()),
+				fmt.Sprintf("arn:aws:iam::%s:role/cdk-%s-*", account, naming.Qualifier),
+			},
+		},
+		{
+			Name: "cloudtrail",
+			Sid:  "DenyCloudTrailTampering",
+			Actions: []string{
+				"cloudtrail:DeleteTrail",
+				"cloudtrail:PutEventSelectors",
+				"cloudtrail:StopLogging",
+				"cloudtrail:UpdateTrail",
+			},
+			Resources: []string{"*"},
+		},
+	}
+}
+
+// guardrailStatements returns the statements of the guardrails not listed
+// in disabled, an unknown name is an error so a typo cannot drop one. The
+// guardrails denying actions on all resources share a statement to keep
+// the boundary within the managed policy size limit.
+func guardrailStatements(naming util.Naming, disabled []string) ([]awsiam.PolicyStatement, error) {
+
+	guardrails := tenantGuardrails(naming)
+
+	known := map[string]bool{}
+	for _, g := range guardrails {
+		known[g.Name] = true
+	}
+
+	off := map[string]bool{}
+	for _, name := range disabled {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			names := make([]string, 0, len(known))
+			for k := range known {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown guardrail %q, one of %s", name, strings.Join(names, ", "))
+		}
+		off[name] = true
+	}
+
+	shared := guardrail{Sid: "DenyEscalation", Resources: []string{"*"}}
+	separate := []guardrail{}
+
+	for _, g := range guardrails {
+		switch {
+		case off[g.Name]:
+		case g.Conditions == nil && len(g.NotResources) == 0 && len(g.Resources) == 1 && g.Resources[0] == "*":
+			shared.Actions = append(shared.Actions, g.Actions...)
+		default:
+			separate = append(separate, g)
+		}
+	}
+
+	statements := []awsiam.PolicyStatement{}
+	for _, g := range append([]guardrail{shared}, separate...) {
+		if len(g.Actions) > 0 {
+			statements = append(statements, g.statement())
+		}
+	}
+
+	return statements, nil
+}
@@ -0,0 +1,103 @@
+package stacks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+)
+
+// an action each guardrail denies
+var guardrailActions = map[string]string{
+	"iam-users":     "iam:*AccessKey*",
+	"organizations": "organizations:*",
+	"admin-access":  "iam:AttachRolePolicy",
+	"foreign-roles": "sts:AssumeRole",
+	"cloudtrail":    "cloudtrail:StopLogging",
+}
+
+func guardrailTemplate(t *testing.T, disabled ...string) assertions.Template {
+	t.Helper()
+
+	props := pipelineProps
+	props.DisabledGuardrails = disabled
+
+	return assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+}
+
+// denying returns the Sid of the Deny statement listing action, if any
+func denying(statements map[string]map[string]interface{}, action string) string {
+	for sid, statement := range statements {
+		if statement["Effect"] == "Deny" && contains(strs(statement["Action"]), action) {
+			return sid
+		}
+	}
+	return ""
+}
+
+func TestGuardrails(t *testing.T) {
+
+	statements := boundaryStatements(guardrailTemplate(t))
+
+	for name, action := range guardrailActions {
+		if denying(statements, action) == "" {
+			t.Errorf("%s: %s is not denied", name, action)
+		}
+	}
+
+	if sid := denying(statements, "organizations:*"); sid != "DenyEscalation" {
+		t.Errorf("unconditional guardrails are in %s, want DenyEscalation", sid)
+	}
+
+	if condition := asJSON(t, statements["DenyAdministratorAccess"]["Condition"]); !strings.Contains(condition, "arn:aws:iam::aws:policy/AdministratorAccess") {
+		t.Errorf("DenyAdministratorAccess: condition is %s", condition)
+	}
+
+	// iam:PolicyARN is only present on the attach calls
+	if actions := strs(statements["DenyAdministratorAccess"]["Action"]); contains(actions, "iam:PutRolePermissionsBoundary") {
+		t.Errorf("DenyAdministratorAccess: denies %v", actions)
+	}
+
+	// only the tenant's and the qualifier's roles may be assumed
+	foreign := asJSON(t, statements["DenyAssumingForeignRoles"]["NotResource"])
+	if !strings.Contains(foreign, ":role/Acme-Test-*") || !strings.Contains(foreign, ":role/cdk-") {
+		t.Errorf("DenyAssumingForeignRoles: exempts %s", foreign)
+	}
+}
+
+func TestGuardrailsDisabled(t *testing.T) {
+
+	disabled := []string{"iam-users", "cloudtrail", "foreign-roles"}
+	statements := boundaryStatements(guardrailTemplate(t, disabled...))
+
+	for name, action := range guardrailActions {
+		switch sid := denying(statements, action); {
+		case contains(disabled, name) && sid != "":
+			t.Errorf("%s: disabled but %s denies %s", name, sid, action)
+		case !contains(disabled, name) && sid == "":
+			t.Errorf("%s: not disabled but %s is not denied", name, action)
+		}
+	}
+
+	if _, ok := statements["DenyAssumingForeignRoles"]; ok {
+		t.Error("DenyAssumingForeignRoles: disabled but in the boundary")
+	}
+}
+
+func TestGuardrailsAllDisabled(t *testing.T) {
+
+	statements := boundaryStatements(guardrailTemplate(t, "iam-users", "organizations", "admin-access", "foreign-roles", "cloudtrail"))
+
+	for _, sid := range []string{"DenyEscalation", "DenyAdministratorAccess", "DenyAssumingForeignRoles"} {
+		if _, ok := statements[sid]; ok {
+			t.Errorf("%s: all guardrails disabled but in the boundary", sid)
+		}
+	}
+}
+
+func TestGuardrailsUnknown(t *testing.T) {
+
+	if _, err := guardrailStatements(testNaming, []string{"iam-user"}); err == nil || !strings.Contains(err.Error(), "iam-users") {
+		t.Errorf("error %v, want the known guardrails", err)
+	}
+}
@@ -0,0 +1,149 @@
+package stacks
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/awskms"
+	"github.com/aws/constructs-go/constructs/v3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// actions needed to use, not manage, a key
+var keyUseActions = []string{
+	"kms:Decrypt",
+	"kms:DescribeKey",
+	"kms:Encrypt",
+	"kms:GenerateDataKey*",
+	"kms:ReEncrypt*",
+}
+
+// actions needed to manage, not use, a key
+var keyAdminActions = []string{
+	"kms:CancelKeyDeletion",
+	"kms:Create*",
+	"kms:Delete*",
+	"kms:Describe*",
+	"kms:Disable*",
+	"kms:Enable*",
+	"kms:Get*",
+	"kms:List*",
+	"kms:Put*",
+	"kms:Revoke*",
+	"kms:ScheduleKeyDeletion",
+	"kms:TagResource",
+	"kms:UntagResource",
+	"kms:Update*",
+}
+
+// pipelineKey returns the customer managed key for bootstrap assets and
+// pipeline artifacts, nil to keep the AWS managed keys
+func pipelineKey(stack awscdk.Stack, props *PipelineStackProps, qualifier string) awskms.IKey {
+
+	switch {
+	case props.KmsKeyArn != "" && props.CreateKmsKey:
+		log.Fatal("Cannot both create a KMS key and use KMS key ", props.KmsKeyArn)
+
+	case props.KmsKeyArn != "":
+		// the key policy is managed with the key, it must allow the qualifier roles
+		if !strings.HasPrefix(props.KmsKeyArn, "arn:") {
+			log.Fatalf("KMS key %q must be given as an ARN", props.KmsKeyArn)
+		}
+		log.Printf("Using KMS key %s\n", props.KmsKeyArn)
+		return awskms.Key_FromKeyArn(stack, jsii.String("PipelineKey"), jsii.String(props.KmsKeyArn))
+
+	case props.CreateKmsKey:
+		return newPipelineKey(stack, props, qualifier)
+	}
+
+	return nil
+}
+
+// newPipelineKey creates a key per tenant. The account may administer it, only
+// the qualifier roles may use it directly and other roles, e.g. the pipeline
+// actions, only through S3 with their own IAM grant.
+func newPipelineKey(stack constructs.Construct, props *PipelineStackProps, qualifier string) awskms.Key {
+
+	policy := awsiam.NewPolicyDocument(nil)
+
+	policy.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowKeyAdministration"),
+		Effect:     awsiam.Effect_ALLOW,
+		Principals: &[]awsiam.IPrincipal{awsiam.NewAccountRootPrincipal()},
+		Actions:    jsii.Strings(keyAdminActions...),
+		Resources:  jsii.Strings("*"),
+	}))
+
+	policy.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowQualifierRoles"),
+		Effect:     awsiam.Effect_ALLOW,
+		Principals: &[]awsiam.IPrincipal{awsiam.NewAccountRootPrincipal()},
+		Actions:    jsii.Strings(keyUseActions...),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"ArnLike": map[string]interface{}{
+				"aws:PrincipalArn": fmt.Sprintf("arn:aws:iam::%s:role/cdk-%s-*", *awscdk.Aws_ACCOUNT_ID(), qualifier),
+			},
+		},
+	}))
+
+	policy.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:        jsii.String("AllowUseThroughS3"),
+		Effect:     awsiam.Effect_ALLOW,
+		Principals: &[]awsiam.IPrincipal{awsiam.NewAccountRootPrincipal()},
+		Actions:    jsii.Strings(keyUseActions...),
+		Resources:  jsii.Strings("*"),
+		Conditions: &map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				"kms:ViaService":    fmt.Sprintf("s3.%s.amazonaws.com", *awscdk.Aws_REGION()),
+				"kms:CallerAccount": *awscdk.Aws_ACCOUNT_ID(),
+			},
+		},
+	}))
+
+	log.Printf("Creating KMS key for tenant %s\n", props.Tenant)
+
+	return awskms.NewKey(stack, jsii.String("PipelineKey"), &awskms.KeyProps{
+		Description:       jsii.String(fmt.Sprintf("Bootstrap assets and pipeline artifacts of %s %s", props.Tenant, props.Application)),
+		Alias:             jsii.String(fmt.Sprintf("alias/%s-pipeline-key", qualifier)),
+		EnableKeyRotation: jsii.Bool(true),
+		Policy:            policy,
+		// assets and artifacts outlive the stack
+		RemovalPolicy: awscdk.RemovalPolicy_RETAIN,
+	})
+}
+
+// keyCapabilities narrow kms:* to use of the pipeline key and of AWS managed
+// keys, which services such as SQS use. Without a customer managed key the
+// AWS managed key ARNs are not known in advance.
+func keyCapabilities(key awskms.IKey) []capability {
+
+	if key == nil {
+		return []capability{{
+			Sid:       "AllowKms",
+			Actions:   []string{"kms:*"},
+			Resources: []string{"*"},
+		}}
+	}
+
+	return []capability{
+		{
+			Sid:       "AllowPipelineKey",
+			Actions:   keyUseActions,
+			Resources: []string{*key.KeyArn()},
+		},
+		{
+			Sid:       "AllowAwsManagedKeys",
+			Actions:   keyUseActions,
+			Resources: []string{"*"},
+			Conditions: map[string]interface{}{
+				"ForAnyValue:StringLike": map[string]interface{}{
+					"kms:ResourceAliases": "alias/aws/*",
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package stacks
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// defaultPassRoleServices lets application stacks pass any of the tenant's
+// roles to Lambda, when no services are configured
+var defaultPassRoleServices = map[string]string{"lambda.amazonaws.com": "*"}
+
+// role names, with * wildcards
+var rolePattern = regexp.MustCompile(`^[\w+=,.@*-]*$`)
+
+// passRoleStatements allows passing the tenant's roles to the services, each
+// limited to the roles matching its pattern after the naming prefix e.g.
+// states.amazonaws.com: *StateMachine* matches Acme-Test-*StateMachine*.
+// Services sharing a pattern share a statement, whose iam:PassedToService
+// condition lists them.
+func passRoleStatements(naming util.Naming, services map[string]string) ([]awsiam.PolicyStatement, error) {
+
+	if len(services) == 0 {
+		services = defaultPassRoleServices
+	}
+
+	byPattern := map[string][]string{}
+	for service, pattern := range services {
+		if !strings.HasSuffix(service, ".amazonaws.com") {
+			return nil, fmt.Errorf("cannot pass roles to %q, not a service principal e.g. states.amazonaws.com", service)
+		}
+		if pattern == "" {
+			pattern = "*"
+		}
+		if !rolePattern.MatchString(pattern) {
+			return nil, fmt.Errorf("role name pattern %q of %s is not a role name with * wildcards", pattern, service)
+		}
+		byPattern[pattern] = append(byPattern[pattern], service)
+	}
+
+	patterns := make([]string, 0, len(byPattern))
+	for pattern := range byPattern {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	statements := []awsiam.PolicyStatement{}
+	for _, pattern := range patterns {
+		principals := byPattern[pattern]
+		sort.Strings(principals)
+
+		sid := "AllowPassRoleTo"
+		for _, principal := range principals {
+			sid += serviceSid(principal)
+		}
+
+		var passedTo interface{} = principals
+		if len(principals) == 1 {
+			passedTo = principals[0]
+		}
+
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:       jsii.String(sid),
+			Effect:    awsiam.Effect_ALLOW,
+			Actions:   jsii.Strings("iam:PassRole"),
+			Resources: jsii.Strings(fmt.Sprintf("arn:aws:iam::%s:role/%s%s", *awscdk.Aws_ACCOUNT_ID(), naming.Prefix(), pattern)),
+			Conditions: &map[string]interface{}{
+				"StringEquals": map[string]interface{}{
+					"iam:PassedToService": passedTo,
+				},
+			},
+		}))
+	}
+
+	return statements, nil
+}
+
+// serviceSid names a service principal in a Sid e.g. EcsTasks for
+// ecs-tasks.amazonaws.com
+func serviceSid(principal string) string {
+
+	name := ""
+	for _, part := range strings.FieldsFunc(strings.TrimSuffix(principal, ".amazonaws.com"), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		name += strings.Title(part)
+	}
+
+	return name
+}
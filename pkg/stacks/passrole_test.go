@@ -0,0 +1,91 @@
+package stacks
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+)
+
+// passedTo returns the services the policy's statements may pass roles to,
+// by role ARN
+func passedTo(statements map[string]map[string]interface{}) map[string][]string {
+
+	passed := map[string][]string{}
+	for _, statement := range statements {
+		if statement["Effect"] != "Allow" || !contains(strs(statement["Action"]), "iam:PassRole") {
+			continue
+		}
+		condition, _ := statement["Condition"].(map[string]interface{})
+		equals, _ := condition["StringEquals"].(map[string]interface{})
+		for _, role := range strs(renderPolicy(statement["Resource"])) {
+			passed[role] = append(passed[role], strs(equals["iam:PassedToService"])...)
+			sort.Strings(passed[role])
+		}
+	}
+
+	return passed
+}
+
+func TestPassRoleServices(t *testing.T) {
+
+	props := pipelineProps
+	props.PassRoleServices = map[string]string{
+		"lambda.amazonaws.com":    "*",
+		"events.amazonaws.com":    "*",
+		"states.amazonaws.com":    "*StateMachine*",
+		"ecs-tasks.amazonaws.com": "*Task*",
+	}
+	template := assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+
+	want := map[string][]string{
+		"arn:aws:iam::123456789012:role/Acme-Test-*":              {"events.amazonaws.com", "lambda.amazonaws.com"},
+		"arn:aws:iam::123456789012:role/Acme-Test-*StateMachine*": {"states.amazonaws.com"},
+		"arn:aws:iam::123456789012:role/Acme-Test-*Task*":         {"ecs-tasks.amazonaws.com"},
+	}
+
+	for _, id := range []string{"DeploymentBoundary", "ExecutionPolicy"} {
+		statements := policyStatements(template, id)
+
+		if got, want := asJSON(t, passedTo(statements)), asJSON(t, want); got != want {
+			t.Errorf("%s: passes roles %s, want %s", id, got, want)
+		}
+
+		for _, sid := range []string{"AllowPassRoleToEventsLambda", "AllowPassRoleToStates", "AllowPassRoleToEcsTasks"} {
+			if _, ok := statements[sid]; !ok {
+				t.Errorf("%s: no %s statement", id, sid)
+			}
+		}
+	}
+}
+
+func TestPassRoleDefault(t *testing.T) {
+
+	template := pipelineTemplate(t)
+
+	want := map[string][]string{"arn:aws:iam::123456789012:role/Acme-Test-*": {"lambda.amazonaws.com"}}
+	if got, want := asJSON(t, passedTo(policyStatements(template, "DeploymentBoundary"))), asJSON(t, want); got != want {
+		t.Errorf("passes roles %s, want %s", got, want)
+	}
+
+	// the application's own roles pass none
+	if passed := passedTo(boundaryStatements(template)); len(passed) != 0 {
+		t.Errorf("PermissionsBoundary: passes roles %v", passed)
+	}
+}
+
+func TestPassRoleInvalid(t *testing.T) {
+
+	for _, services := range []map[string]string{
+		{"states": "*"},
+		{"lambda.amazonaws.com": "role/*"},
+		{"lambda.amazonaws.com": "a b"},
+	} {
+		if _, err := passRoleStatements(testNaming, services); err == nil {
+			t.Errorf("%v: no error", services)
+		} else if !strings.Contains(err.Error(), "amazonaws.com") {
+			t.Errorf("%v: error %v does not name the service", services, err)
+		}
+	}
+}
@@ -6,14 +6,17 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"permission-boundary-pipeline-cdk/pkg/bootstrap"
+	"permission-boundary-pipeline-cdk/pkg/hosting"
 	"permission-boundary-pipeline-cdk/pkg/util"
-	"strings"
 
 	"github.com/aws/aws-cdk-go/awscdk"
 	"github.com/aws/aws-cdk-go/awscdk/awscodebuild"
 	"github.com/aws/aws-cdk-go/awscdk/awscodepipeline"
 	"github.com/aws/aws-cdk-go/awscdk/awscodepipelineactions"
 	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/awss3"
 	"github.com/aws/aws-cdk-go/awscdk/cloudformationinclude"
 	"github.com/aws/aws-cdk-go/awscdk/pipelines"
 
@@ -25,22 +28,35 @@ import (
 const GOVERSION = "1.17.2"
 
 type PipelineStackProps struct {
-	Tenant       string            `envconfig:"TENANT" default:"openenterprise"`
-	Environment  string            `envconfig:"ENVIRONMENT" default:"staging"`
-	Application  string            `envconfig:"APPLICATION" default:"superapp4000"`
-	GithubOrg    string            `envconfig:"GITHUB_ORG" default:"NixM0nk3y"`
-	GithubRepo   string            `envconfig:"GITHUB_REPO" default:"permission-boundary-pipeline-cdk"`
-	GithubBranch string            `envconfig:"GITHUB_BRANCH" default:"main"`
-	StackProps   awscdk.StackProps ``
+	Tenant       string `envconfig:"TENANT" default:"openenterprise"`
+	Environment  string `envconfig:"ENVIRONMENT" default:"staging"`
+	Application  string `envconfig:"APPLICATION" default:"superapp4000"`
+	GithubOrg    string `envconfig:"GITHUB_ORG" default:"NixM0nk3y"`
+	GithubRepo   string `envconfig:"GITHUB_REPO" default:"permission-boundary-pipeline-cdk"`
+	GithubBranch string `envconfig:"GITHUB_BRANCH" default:"main"`
+	// tagged on the application resources
+	CostCentre string `envconfig:"COST_CENTRE"`
+	// bootstrap template file, generated with the cdk CLI when empty
+	BootstrapTemplate string `envconfig:"BOOTSTRAP_TEMPLATE"`
+	// customer managed key for bootstrap assets and pipeline artifacts, AWS
+	// managed keys are used when neither is set
+	KmsKeyArn    string `envconfig:"KMS_KEY_ARN"`
+	CreateKmsKey bool   `envconfig:"CREATE_KMS_KEY" default:"false"`
+	// boundary guardrails to leave out e.g. iam-users,cloudtrail
+	DisabledGuardrails []string `envconfig:"DISABLED_GUARDRAILS"`
+	// services roles may be passed to, with the role name pattern after the
+	// naming prefix e.g. lambda.amazonaws.com:*,states.amazonaws.com:*Sfn*,
+	// Lambda only when empty
+	PassRoleServices map[string]string `envconfig:"PASS_ROLE_SERVICES"`
+	// VPC the application functions are attached to, the boundary grants the
+	// network services only when enabled
+	Vpc        hosting.VpcProps  `envconfig:"VPC"`
+	StackProps awscdk.StackProps ``
 }
 
-var conditionRestrictToRegions = &map[string]interface{}{
-	"StringEquals": &map[string]interface{}{
-		"aws:RequestedRegion": jsii.Strings(
-			"us-east-1", // Allow North Virginia for CloudFront.
-			"eu-west-1", // Europe.
-		),
-	},
+var allowedRegions = []string{
+	"us-east-1", // Allow North Virginia for CloudFront.
+	"eu-west-1", // Europe.
 }
 
 //
@@ -62,7 +78,6 @@ func GenerateBootStrapTemplate(Qualifier string) *os.File {
 		fmt.Sprintf("aws://%s/%s", *awscdk.Aws_ACCOUNT_ID(), *awscdk.Aws_REGION()),
 		"--require-approval", "never",
 		fmt.Sprintf("--toolkit-stack-name=%s-CDKToolkit", Qualifier),
-		"--cloudformation-execution-policies=arn:aws:iam::aws:policy/AdministratorAccess",
 		"--show-template",
 	)
 
@@ -96,14 +111,47 @@ func PipelineStack(scope constructs.Construct, id string, props *PipelineStackPr
 	CdkQualifier := util.CalculateQualifier(props.Tenant, props.Application)
 	log.Printf("Generated Qualifier: %s\n", CdkQualifier)
 
+	// the boundaries only cover names the naming prefix delimits
+	if err := tenantNaming(props, CdkQualifier).Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// the application stack cannot be synthesized without it
+	if props.CostCentre == "" {
+		log.Fatal("No cost centre for the application resources, set CDK_COST_CENTRE")
+	}
+
 	stack := awscdk.NewStack(scope, &id, &sprops)
 
+	key := pipelineKey(stack, props, CdkQualifier)
+	if key != nil {
+		awscdk.NewCfnOutput(stack, jsii.String("PipelineKeyArn"), &awscdk.CfnOutputProps{
+			Value: key.KeyArn(),
+		})
+	}
+
 	// generate our permissions boundary
-	permissionsBoundary := addPermissionsBoundary(stack, props, CdkQualifier)
+	permissionsBoundary := addPermissionsBoundary(stack, props, CdkQualifier, key)
 	awscdk.NewCfnOutput(stack, jsii.String("PermissionsBoundaryArn"), &awscdk.CfnOutputProps{
 		Value: permissionsBoundary.ManagedPolicyArn(),
 	})
 
+	// the CloudFormation execution role's boundary
+	deploymentBoundary := addDeploymentBoundary(stack, props, CdkQualifier, key)
+	awscdk.NewCfnOutput(stack, jsii.String("DeploymentBoundaryArn"), &awscdk.CfnOutputProps{
+		Value: deploymentBoundary.ManagedPolicyArn(),
+	})
+
+	// the execution role's own permissions, should the boundary be detached
+	executionPolicy := addExecutionPolicy(stack, props, CdkQualifier, key)
+	executionGuardrails := addExecutionGuardrails(stack, props, CdkQualifier)
+
+	for _, policy := range []awsiam.ManagedPolicy{permissionsBoundary, deploymentBoundary, executionPolicy, executionGuardrails} {
+		if size := policySize(policy); size > maxPolicySize {
+			log.Fatalf("%s is %d characters, over the managed policy limit of %d, share PassRole patterns", *policy.Node().Id(), size, maxPolicySize)
+		}
+	}
+
 	token := awscdk.NewCfnParameter(stack, jsii.String("GithubToken"), &awscdk.CfnParameterProps{
 		Type:   jsii.String("String"),
 		NoEcho: jsii.Bool(true),
@@ -118,34 +166,80 @@ func PipelineStack(scope constructs.Construct, id string, props *PipelineStackPr
 		Branch:     jsii.String(props.GithubBranch),
 		OauthToken: awscdk.SecretValue_PlainText(awscdk.Token_AsString(token.Value(), &awscdk.EncodingOptions{})),
 		Output:     sourceArtifact,
+		// exposes the commit metadata to later actions
+		VariablesNamespace: jsii.String("SourceVariables"),
 	})
 
 	cloudAssemblyArtifact := awscodepipeline.NewArtifact(jsii.String("cloudAssemblyArtifact"))
 
+	environment := map[string]*awscodebuild.BuildEnvironmentVariable{
+		"TENANT": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String(props.Tenant),
+		},
+		"ENVIRONMENT": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String(props.Environment),
+		},
+		"COST_CENTRE": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String(props.CostCentre),
+		},
+		// build provenance for the api binary
+		"PIPELINE_EXECUTION_ID": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String("#{codepipeline.PipelineExecutionId}"),
+		},
+		"SOURCE_REPO": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String(fmt.Sprintf("https://github.com/%s/%s", props.GithubOrg, props.GithubRepo)),
+		},
+		// reproducible build date, the checkout has no git history
+		"COMMIT_DATE": {
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: githubAction.Variables().CommitterDate,
+		},
+	}
+
+	// the application synth attaches the functions as the boundary allows
+	for name, value := range props.Vpc.Environment() {
+		environment[name] = &awscodebuild.BuildEnvironmentVariable{
+			Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
+			Value: jsii.String(value),
+		}
+	}
+
 	deployAction := pipelines.NewSimpleSynthAction(&pipelines.SimpleSynthActionProps{
 		CloudAssemblyArtifact: cloudAssemblyArtifact,
 		SourceArtifact:        sourceArtifact,
 		InstallCommands:       jsii.Strings("npm install aws-cdk -g", "cd $HOME/.goenv && git pull --ff-only && cd -", "goenv install "+GOVERSION, "goenv local "+GOVERSION),
-		SynthCommand:          jsii.String("make ci/deploy/application"),
-		EnvironmentVariables: &map[string]*awscodebuild.BuildEnvironmentVariable{
-			"TENANT": {
-				Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
-				Value: jsii.String(props.Tenant),
-			},
-			"ENVIRONMENT": {
-				Type:  awscodebuild.BuildEnvironmentVariableType_PLAINTEXT,
-				Value: jsii.String(props.Environment),
-			},
-		},
+		SynthCommand:          jsii.String("make ci/deploy/application ci/smoke/application"),
+		EnvironmentVariables:  &environment,
 	})
 
-	pipelines.NewCdkPipeline(stack, jsii.String("CdkPipeline"), &pipelines.CdkPipelineProps{
+	pipelineProps := &pipelines.CdkPipelineProps{
 		CloudAssemblyArtifact: sourceArtifact,
 		SelfMutating:          jsii.Bool(false),
 		CrossAccountKeys:      jsii.Bool(false),
 		SourceAction:          githubAction,
 		SynthAction:           deployAction,
-	})
+	}
+
+	// artifacts encrypted with our key need a bucket of our own
+	if key != nil {
+		pipelineProps.CrossAccountKeys = nil
+		pipelineProps.CodePipeline = awscodepipeline.NewPipeline(stack, jsii.String("Pipeline"), &awscodepipeline.PipelineProps{
+			ArtifactBucket: awss3.NewBucket(stack, jsii.String("ArtifactBucket"), &awss3.BucketProps{
+				Encryption:        awss3.BucketEncryption_KMS,
+				EncryptionKey:     key,
+				BucketKeyEnabled:  jsii.Bool(true),
+				BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+				EnforceSSL:        jsii.Bool(true),
+			}),
+		})
+	}
+
+	pipelines.NewCdkPipeline(stack, jsii.String("CdkPipeline"), pipelineProps)
 
 	deployAction.Project().Role().AddToPolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
 		Actions: jsii.Strings("sts:AssumeRole"),
@@ -165,18 +259,31 @@ func PipelineStack(scope constructs.Construct, id string, props *PipelineStackPr
 	}))
 
 	// create our bootstrap CDK stack with our qualifier
-	bootstrapTemplate := GenerateBootStrapTemplate(CdkQualifier)
-	defer os.Remove(bootstrapTemplate.Name())
+	templateFile := props.BootstrapTemplate
+	if templateFile == "" {
+		bootstrapTemplate := GenerateBootStrapTemplate(CdkQualifier)
+		defer os.Remove(bootstrapTemplate.Name())
+		templateFile = bootstrapTemplate.Name()
+	}
+	bootstrapParameters := map[string]interface{}{
+		"Qualifier": CdkQualifier,
+		// in place of AdministratorAccess
+		"CloudFormationExecutionPolicies": []*string{executionPolicy.ManagedPolicyArn(), executionGuardrails.ManagedPolicyArn()},
+	}
+
+	// otherwise FileAssetsBucketKmsKeyId is left to cdk deploy --parameters
+	if key != nil {
+		bootstrapParameters["FileAssetsBucketKmsKeyId"] = key.KeyId()
+	}
+
 	template := cloudformationinclude.NewCfnInclude(stack, jsii.String("BootStrap"), &cloudformationinclude.CfnIncludeProps{
-		TemplateFile: jsii.String(bootstrapTemplate.Name()),
-		Parameters: &map[string]interface{}{
-			"Qualifier": CdkQualifier,
-		},
+		TemplateFile: jsii.String(templateFile),
+		Parameters:   &bootstrapParameters,
 	})
 
-	// attach a PermissionsBoundary to the CF Role
+	// attach the deployment boundary to the CF Role
 	cfnExecRole := template.GetResource(jsii.String("CloudFormationExecutionRole"))
-	cfnExecRole.AddPropertyOverride(jsii.String("PermissionsBoundary"), jsii.String(*permissionsBoundary.ManagedPolicyArn()))
+	cfnExecRole.AddPropertyOverride(jsii.String("PermissionsBoundary"), jsii.String(*deploymentBoundary.ManagedPolicyArn()))
 
 	// lock down the roles to our pipeline
 	pipelinePolicy := awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
@@ -188,22 +295,13 @@ func PipelineStack(scope constructs.Construct, id string, props *PipelineStackPr
 		},
 	})
 
-	// remove the account level trust and replace just with the pipeline role
-	cfnFilePublishingRole := template.GetResource(jsii.String("FilePublishingRole"))
-	cfnFilePublishingRole.AddPropertyDeletionOverride(jsii.String("AssumeRolePolicyDocument.Statement.0"))
-	cfnFilePublishingRole.AddPropertyOverride(jsii.String("AssumeRolePolicyDocument.Statement.2"), pipelinePolicy.ToStatementJson())
-
-	cfnImagePublishingRole := template.GetResource(jsii.String("ImagePublishingRole"))
-	cfnImagePublishingRole.AddPropertyDeletionOverride(jsii.String("AssumeRolePolicyDocument.Statement.0"))
-	cfnImagePublishingRole.AddPropertyOverride(jsii.String("AssumeRolePolicyDocument.Statement.2"), pipelinePolicy.ToStatementJson())
-
-	cfnDeploymentActionRole := template.GetResource(jsii.String("DeploymentActionRole"))
-	cfnDeploymentActionRole.AddPropertyDeletionOverride(jsii.String("AssumeRolePolicyDocument.Statement.0"))
-	cfnDeploymentActionRole.AddPropertyOverride(jsii.String("AssumeRolePolicyDocument.Statement.2"), pipelinePolicy.ToStatementJson())
-
-	cfnLookupRole := template.GetResource(jsii.String("LookupRole"))
-	cfnLookupRole.AddPropertyDeletionOverride(jsii.String("AssumeRolePolicyDocument.Statement.0"))
-	cfnLookupRole.AddPropertyOverride(jsii.String("AssumeRolePolicyDocument.Statement.3"), pipelinePolicy.ToStatementJson())
+	// remove the account level trust and replace just with the pipeline role,
+	// bootstrapdiff checks the statement indexes against new templates
+	for _, override := range bootstrap.TrustOverrides {
+		role := template.GetResource(jsii.String(override.Resource))
+		role.AddPropertyDeletionOverride(jsii.String(fmt.Sprintf("AssumeRolePolicyDocument.Statement.%d", override.Remove)))
+		role.AddPropertyOverride(jsii.String(fmt.Sprintf("AssumeRolePolicyDocument.Statement.%d", override.Append)), pipelinePolicy.ToStatementJson())
+	}
 
 	return stack
 }
@@ -211,17 +309,51 @@ func PipelineStack(scope constructs.Construct, id string, props *PipelineStackPr
 //
 // Initially cribbed from https://adrianhesketh.com/2021/09/02/secure-your-aws-ci-cd-pipelines-with-a-permissions-boundary/
 //
-func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProps, Qualifier string) (pb awsiam.ManagedPolicy) {
+func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProps, Qualifier string, key awskms.IKey) (pb awsiam.ManagedPolicy) {
 
 	boundaryNameTemplate := awscdk.Fn_Sub(jsii.String(fmt.Sprintf("%s-permissions-boundary-${AWS::AccountId}", Qualifier)), nil)
-	boundaryArnTemplate := awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:iam::${AWS::AccountId}:policy/%s-permissions-boundary-${AWS::AccountId}", Qualifier)), nil)
 
-	resourceApplicationRoleWildcard := fmt.Sprintf("arn:aws:iam::%s:role/%s%s*", *awscdk.Aws_ACCOUNT_ID(), strings.Title(props.Tenant), strings.Title(props.Environment))
+	naming := tenantNaming(props, Qualifier)
 
 	// Create a permission boundary.
 	pb = awsiam.NewManagedPolicy(stack, jsii.String("PermissionsBoundary"), &awsiam.ManagedPolicyProps{
 		ManagedPolicyName: boundaryNameTemplate,
-		Description:       jsii.String("Permission boundary to limit permissions of roles created by CI/CD user."),
+		Description:       jsii.String("Permission boundary of the roles the tenant's application stacks create."),
+	})
+
+	// Allow the services application stacks use, on the tenant's resources only.
+	pb.AddStatements(capabilityStatements(tenantCapabilities(naming, key, props.Vpc.Enabled))...)
+
+	// Deny leaving the allowed regions.
+	pb.AddStatements(regionGuard())
+
+	// Deny reaching resources tagged for another tenant.
+	pb.AddStatements(tenantTagGuards(naming)...)
+
+	// Deny privilege escalation paths.
+	guardrails, err := guardrailStatements(naming, props.DisabledGuardrails)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pb.AddStatements(guardrails...)
+
+	pb.AddStatements(boundaryGuards(Qualifier)...)
+
+	return pb
+}
+
+// addDeploymentBoundary creates the boundary of the CloudFormation execution
+// role, which deploys the application and creates its roles with the
+// application boundary. A role carries a single boundary and a managed
+// policy is limited in size, so the deployment permissions have a boundary
+// of their own. The execution policy carries the tag guards and guardrails.
+func addDeploymentBoundary(stack constructs.Construct, props *PipelineStackProps, Qualifier string, key awskms.IKey) awsiam.ManagedPolicy {
+
+	naming := tenantNaming(props, Qualifier)
+
+	pb := awsiam.NewManagedPolicy(stack, jsii.String("DeploymentBoundary"), &awsiam.ManagedPolicyProps{
+		ManagedPolicyName: awscdk.Fn_Sub(jsii.String(fmt.Sprintf("%s-deployment-boundary-${AWS::AccountId}", Qualifier)), nil),
+		Description:       jsii.String("Permission boundary of the CloudFormation execution role."),
 	})
 
 	// Allow reading IAM information, and simulating policies.
@@ -236,27 +368,11 @@ func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProp
 		Resources: jsii.Strings("*"),
 	}))
 
-	// Allow services that need a wildcard resource ID because the resource path is unknown in advance e.g. API Gateway.
-	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:    jsii.String("AllowServerlessServices"),
-		Effect: awsiam.Effect_ALLOW,
-		Actions: jsii.Strings(
-			"apigateway:*",
-			"dynamodb:*",
-			"ec2:CreateNetworkInterface",
-			"ec2:DeleteNetworkInterface",
-			"ec2:Describe*",
-			"kms:*",
-			"lambda:*",
-			"logs:*",
-			"s3:*",
-			"secretsmanager:*",
-			"ssm:*",
-			"xray:*",
-		),
-		Resources:  jsii.Strings("*"),
-		Conditions: conditionRestrictToRegions,
-	}))
+	// Allow the services application stacks deploy, on the tenant's resources only.
+	pb.AddStatements(capabilityStatements(deploymentCapabilities(naming, key, props.Vpc.Enabled))...)
+
+	// Deny leaving the allowed regions.
+	pb.AddStatements(regionGuard())
 
 	// Allow CloudFormation deployment.
 	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
@@ -274,60 +390,20 @@ func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProp
 			"cloudformation:ValidateTemplate",
 			"cloudformation:DeleteStack",
 		),
-		Resources:  jsii.Strings("*"),
-		Conditions: conditionRestrictToRegions,
-	}))
-
-	// Allow validation of any stack.
-	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:    jsii.String("AllowValidationOfAnyStack"),
-		Effect: awsiam.Effect_ALLOW,
-		Actions: jsii.Strings(
-			"cloudformation:ValidateTemplate",
-		),
-		Resources:  jsii.Strings("*"),
-		Conditions: conditionRestrictToRegions,
-	}))
-
-	// Allow passing any roles that start with the application name to Lambda.
-	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:    jsii.String("AllowPassRoleToLambda"),
-		Effect: awsiam.Effect_ALLOW,
-		Actions: jsii.Strings(
-			"iam:PassRole",
-		),
-		Resources: jsii.Strings(resourceApplicationRoleWildcard),
-		Conditions: &map[string]interface{}{
-			"StringEquals": &map[string]interface{}{
-				"iam:PassedToService": jsii.String("lambda.amazonaws.com"),
-			},
-		},
+		// including validation of any stack
+		Resources: jsii.Strings("*"),
 	}))
 
-	// Deny permissions boundary alteration.
-	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:    jsii.String("DenyPermissionsBoundaryAlteration"),
-		Effect: awsiam.Effect_DENY,
-		Actions: jsii.Strings(
-			"iam:CreatePolicyVersion",
-			"iam:DeletePolicy",
-			"iam:DeletePolicyVersion",
-			"iam:SetDefaultPolicyVersion",
-		),
-		Resources: &[]*string{boundaryArnTemplate},
-	}))
+	// Allow passing roles that start with the application name to the listed services.
+	passRoles, err := passRoleStatements(naming, props.PassRoleServices)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pb.AddStatements(passRoles...)
 
-	// Deny removal of permissions boundary from any role.
-	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-		Sid:    jsii.String("DenyPermissionsBoundaryRemoval"),
-		Effect: awsiam.Effect_DENY,
-		Actions: jsii.Strings(
-			"iam:DeleteRolePermissionsBoundary",
-		),
-		Resources: jsii.Strings("*"),
-	}))
+	pb.AddStatements(boundaryGuards(Qualifier)...)
 
-	// Allow permissions boundaries to be applied.
+	// Allow roles to be created with the application boundary applied.
 	pb.AddStatements(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
 		Sid:    jsii.String("AllowUpsertRoleIfPermBoundaryIsBeingApplied"),
 		Effect: awsiam.Effect_ALLOW,
@@ -343,7 +419,7 @@ func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProp
 		Resources: jsii.Strings("*"),
 		Conditions: &map[string]interface{}{
 			"StringEquals": &map[string]interface{}{
-				"iam:PermissionsBoundary": boundaryArnTemplate,
+				"iam:PermissionsBoundary": boundaryArn(Qualifier),
 			},
 		},
 	}))
@@ -375,3 +451,52 @@ func addPermissionsBoundary(stack constructs.Construct, props *PipelineStackProp
 
 	return pb
 }
+
+// boundaryGuards deny changing either boundary, or removing one from a role
+func boundaryGuards(Qualifier string) []awsiam.PolicyStatement {
+	return []awsiam.PolicyStatement{
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:    jsii.String("DenyPermissionsBoundaryAlteration"),
+			Effect: awsiam.Effect_DENY,
+			Actions: jsii.Strings(
+				"iam:CreatePolicyVersion",
+				"iam:DeletePolicy",
+				"iam:DeletePolicyVersion",
+				"iam:SetDefaultPolicyVersion",
+			),
+			Resources: &[]*string{
+				boundaryArn(Qualifier),
+				deploymentBoundaryArn(Qualifier),
+			},
+		}),
+		awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Sid:    jsii.String("DenyPermissionsBoundaryRemoval"),
+			Effect: awsiam.Effect_DENY,
+			Actions: jsii.Strings(
+				"iam:DeleteRolePermissionsBoundary",
+			),
+			Resources: jsii.Strings("*"),
+		}),
+	}
+}
+
+// tenantNaming is the naming convention the boundary scopes the tenant's
+// application resources by
+func tenantNaming(props *PipelineStackProps, Qualifier string) util.Naming {
+	return util.Naming{
+		Tenant:      props.Tenant,
+		Environment: props.Environment,
+		Application: props.Application,
+		Qualifier:   Qualifier,
+	}
+}
+
+// boundaryArn is the ARN of the permissions boundary, known before it is created
+func boundaryArn(Qualifier string) *string {
+	return awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:iam::${AWS::AccountId}:policy/%s-permissions-boundary-${AWS::AccountId}", Qualifier)), nil)
+}
+
+// deploymentBoundaryArn is the ARN of the execution role's boundary
+func deploymentBoundaryArn(Qualifier string) *string {
+	return awscdk.Fn_Sub(jsii.String(fmt.Sprintf("arn:aws:iam::${AWS::AccountId}:policy/%s-deployment-boundary-${AWS::AccountId}", Qualifier)), nil)
+}
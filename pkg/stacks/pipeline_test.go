@@ -0,0 +1,560 @@
+package stacks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"permission-boundary-pipeline-cdk/pkg/hosting"
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var pipelineProps = PipelineStackProps{
+	Tenant:            "acme",
+	Environment:       "test",
+	Application:       "app",
+	GithubOrg:         "org",
+	GithubRepo:        "repo",
+	GithubBranch:      "main",
+	CostCentre:        "cc-1234",
+	BootstrapTemplate: bootstrapTemplate,
+}
+
+func pipelineTemplate(t *testing.T) assertions.Template {
+	t.Helper()
+
+	props := pipelineProps
+
+	return assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+}
+
+func TestPipelineBoundary(t *testing.T) {
+
+	template := pipelineTemplate(t)
+	qualifier := util.CalculateQualifier(pipelineProps.Tenant, pipelineProps.Application)
+
+	statements := []map[string]interface{}{
+		{"Sid": "AllowIAMReadOnly", "Effect": "Allow"},
+		{"Sid": "AllowUnscoped", "Effect": "Allow", "Resource": "*"},
+		{"Sid": "AllowTenantResources", "Effect": "Allow"},
+		{"Sid": "DenyOtherRegions", "Effect": "Deny", "Action": "*"},
+		{"Sid": "DenyOtherTenantResources", "Effect": "Deny", "Action": "*"},
+		{"Sid": "DenyOtherTenantRequestTags", "Effect": "Deny", "Action": "*"},
+		{"Sid": "DenyUntaggedCreates", "Effect": "Deny", "Action": assertions.Match_ArrayWith(&[]interface{}{
+			"dynamodb:CreateTable", "logs:CreateLogGroup", "secretsmanager:CreateSecret", "sns:CreateTopic", "sqs:CreateQueue",
+		}), "Condition": map[string]interface{}{
+			"Null": map[string]interface{}{"aws:RequestTag/Tenant": "true"},
+		}},
+		{"Sid": "AllowCloudFormationDeployment", "Effect": "Allow"},
+		{"Sid": "AllowPassRoleToLambda", "Effect": "Allow", "Action": "iam:PassRole"},
+		{"Sid": "DenyPermissionsBoundaryAlteration", "Effect": "Deny"},
+		{"Sid": "DenyPermissionsBoundaryRemoval", "Effect": "Deny", "Action": "iam:DeleteRolePermissionsBoundary"},
+		{"Sid": "AllowUpsertRoleIfPermBoundaryIsBeingApplied", "Effect": "Allow"},
+	}
+
+	for _, statement := range statements {
+		statement := statement
+		check(t, statement["Sid"].(string), func() {
+			template.HasResourceProperties(jsii.String("AWS::IAM::ManagedPolicy"), map[string]interface{}{
+				"PolicyDocument": assertions.Match_ObjectLike(&map[string]interface{}{
+					"Statement": assertions.Match_ArrayWith(&[]interface{}{
+						assertions.Match_ObjectLike(&statement),
+					}),
+				}),
+			})
+		})
+	}
+
+	check(t, "boundary name", func() {
+		template.HasResourceProperties(jsii.String("AWS::IAM::ManagedPolicy"), map[string]interface{}{
+			"ManagedPolicyName": map[string]interface{}{
+				"Fn::Sub": qualifier + "-permissions-boundary-${AWS::AccountId}",
+			},
+		})
+	})
+
+	// only the application stack's own CDK functions are exempt
+	exempt := asJSON(t, boundaryStatements(template)["DenyUntaggedCreates"]["NotResource"])
+	for _, function := range []string{":function:Acme-Test-ApplicationStack-LogRetention*", ":function:Acme-Test-ApplicationStack-BucketNotificationsHandler*"} {
+		if !strings.Contains(exempt, function) {
+			t.Errorf("DenyUntaggedCreates: exempts %s, not %s", exempt, function)
+		}
+	}
+
+	check(t, "boundary applied with upserts", func() {
+		template.HasResourceProperties(jsii.String("AWS::IAM::ManagedPolicy"), map[string]interface{}{
+			"PolicyDocument": assertions.Match_ObjectLike(&map[string]interface{}{
+				"Statement": assertions.Match_ArrayWith(&[]interface{}{
+					assertions.Match_ObjectLike(&map[string]interface{}{
+						"Sid": "AllowUpsertRoleIfPermBoundaryIsBeingApplied",
+						"Condition": map[string]interface{}{
+							"StringEquals": map[string]interface{}{
+								"iam:PermissionsBoundary": map[string]interface{}{
+									"Fn::Sub": "arn:aws:iam::${AWS::AccountId}:policy/" + qualifier + "-permissions-boundary-${AWS::AccountId}",
+								},
+							},
+						},
+					}),
+				}),
+			}),
+		})
+	})
+}
+
+// boundaryStatements returns the boundary's statements by Sid
+func boundaryStatements(template assertions.Template) map[string]map[string]interface{} {
+	return policyStatements(template, "PermissionsBoundary")
+}
+
+// policyDocument returns the document of the managed policy whose logical
+// ID starts with id
+func policyDocument(template assertions.Template, id string) map[string]interface{} {
+
+	for logicalID, policy := range resources(template, "AWS::IAM::ManagedPolicy") {
+		if strings.HasPrefix(logicalID, id) {
+			return policy["Properties"].(map[string]interface{})["PolicyDocument"].(map[string]interface{})
+		}
+	}
+
+	return map[string]interface{}{"Statement": []interface{}{}}
+}
+
+// policyStatements returns the statements by Sid of the managed policy whose
+// logical ID starts with id
+func policyStatements(template assertions.Template, id string) map[string]map[string]interface{} {
+
+	statements := map[string]map[string]interface{}{}
+	for _, s := range policyDocument(template, id)["Statement"].([]interface{}) {
+		statement := s.(map[string]interface{})
+		if sid, ok := statement["Sid"].(string); ok {
+			statements[sid] = statement
+		}
+	}
+
+	return statements
+}
+
+// strs reads a policy element which is a string or a list of strings
+func strs(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := []string{}
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func TestPipelineBoundaryScope(t *testing.T) {
+
+	statements := boundaryStatements(pipelineTemplate(t))
+
+	// service wildcards must not be granted on every resource
+	for sid, statement := range statements {
+		if statement["Effect"] != "Allow" || statement["Resource"] != "*" {
+			continue
+		}
+		for _, action := range strs(statement["Action"]) {
+			if strings.HasSuffix(action, ":*") && action != "xray:*" && action != "kms:*" {
+				t.Errorf("%s: grants %s on all resources", sid, action)
+			}
+		}
+	}
+
+	tenant, ok := statements["AllowTenantResources"]
+	if !ok {
+		t.Fatal("AllowTenantResources: missing from the boundary")
+	}
+
+	resource := asJSON(t, tenant["Resource"])
+	for _, scope := range []string{
+		`"arn:aws:s3:::acme-test-*"`,
+		":function:Acme-Test-*",
+		":table/Acme-Test-*",
+		":secret:acme/test/*",
+		":parameter/acme/test/*",
+		":rule/Acme-Test-*",
+	} {
+		if !strings.Contains(resource, scope) {
+			t.Errorf("AllowTenantResources: resources %s are not scoped to %s", resource, scope)
+		}
+	}
+
+	// API ARNs carry no name, APIs are scoped by the tenant tag
+	for sid, want := range map[string]string{
+		"AllowApiGatewayCreate": `{"StringEquals":{"aws:RequestTag/Tenant":"acme"}}`,
+		"AllowApiGateway":       `{"StringEquals":{"aws:ResourceTag/Tenant":"acme"}}`,
+	} {
+		if condition := asJSON(t, statements[sid]["Condition"]); condition != want {
+			t.Errorf("%s: condition is %s", sid, condition)
+		}
+	}
+
+	// narrower grants of a service already granted on the tenant's resources
+	// must keep their own statement
+	for _, sid := range []string{"AllowStagingBucketRead", "AllowPutEvents", "AllowBootstrapVersion", "AllowLambdaEventSources"} {
+		if _, ok := statements[sid]; !ok {
+			t.Errorf("%s: merged into another statement", sid)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IAM limits managed policies to 6144 characters without white space
+func TestPipelineBoundarySize(t *testing.T) {
+
+	withKey := pipelineProps
+	withKey.CreateKmsKey = true
+
+	// a PassRole statement per role name pattern
+	passRoles := withKey
+	passRoles.PassRoleServices = map[string]string{
+		"lambda.amazonaws.com": "*",
+		"events.amazonaws.com": "*",
+		"states.amazonaws.com": "*StateMachine*",
+	}
+
+	// the network services and endpoints next to the PassRole patterns
+	vpc := passRoles
+	vpc.Vpc = hosting.VpcProps{Enabled: true, Name: "shared", Endpoints: true}
+
+	for _, props := range []PipelineStackProps{pipelineProps, withKey, passRoles, vpc} {
+		props := props
+		template := assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+
+		for id, policy := range resources(template, "AWS::IAM::ManagedPolicy") {
+			document, err := json.Marshal(renderPolicy(policy["Properties"].(map[string]interface{})["PolicyDocument"]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(document) > maxPolicySize {
+				t.Errorf("%s: %d characters, over the managed policy limit", id, len(document))
+			}
+		}
+	}
+}
+
+// the synth measures the policies as the test does
+func TestPolicySize(t *testing.T) {
+
+	props := pipelineProps
+	stack := PipelineStack(newApp(t), "Pipeline", &props)
+	template := assertions.Template_FromStack(stack)
+
+	for _, id := range []string{"PermissionsBoundary", "DeploymentBoundary", "ExecutionPolicy"} {
+		policy := stack.Node().FindChild(jsii.String(id)).(awsiam.ManagedPolicy)
+
+		document, err := json.Marshal(renderPolicy(policyDocument(template, id)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := policySize(policy); size != len(document) {
+			t.Errorf("%s: measured %d characters, synthesized %d", id, size, len(document))
+		}
+	}
+}
+
+func TestPipelineRoleArns(t *testing.T) {
+
+	template := pipelineTemplate(t)
+	qualifier := util.CalculateQualifier(pipelineProps.Tenant, pipelineProps.Application)
+
+	policies := ""
+	for _, policy := range resources(template, "AWS::IAM::Policy") {
+		policies += asJSON(t, policy)
+	}
+
+	for _, role := range []string{"deploy-role", "file-publishing-role", "image-publishing-role", "lookup-role", "cfn-exec-role"} {
+		if !strings.Contains(policies, ":role/cdk-"+qualifier+"-"+role+"-") {
+			t.Errorf("no policy grants the %s of qualifier %s", role, qualifier)
+		}
+	}
+
+	if strings.Contains(policies, ":role/cdk-hnb659fds-") {
+		t.Error("a policy grants the default qualifier roles")
+	}
+}
+
+func TestPipelineBootstrapTrust(t *testing.T) {
+
+	template := pipelineTemplate(t)
+	roles := resources(template, "AWS::IAM::Role")
+
+	for _, id := range []string{"FilePublishingRole", "ImagePublishingRole", "DeploymentActionRole", "LookupRole"} {
+
+		role, ok := roles[id]
+		if !ok {
+			t.Errorf("%s: missing from the bootstrap", id)
+			continue
+		}
+
+		trust := asJSON(t, role["Properties"].(map[string]interface{})["AssumeRolePolicyDocument"])
+
+		// the account root must no longer be trusted, only the pipeline
+		if strings.Contains(trust, `"AWS":{"Ref":"AWS::AccountId"}`) {
+			t.Errorf("%s: still trusts the account: %s", id, trust)
+		}
+
+		if !strings.Contains(trust, `"Sid":"AllowCodebuild"`) || !strings.Contains(trust, "CdkPipelineBuildSynthCdkBuildProjectRole") {
+			t.Errorf("%s: does not trust the pipeline build role: %s", id, trust)
+		}
+	}
+
+	exec, ok := roles["CloudFormationExecutionRole"]
+	if !ok {
+		t.Fatal("CloudFormationExecutionRole: missing from the bootstrap")
+	}
+
+	if boundary := asJSON(t, exec["Properties"].(map[string]interface{})["PermissionsBoundary"]); !strings.Contains(boundary, "DeploymentBoundary") {
+		t.Errorf("CloudFormationExecutionRole: boundary is %s", boundary)
+	}
+}
+
+func TestPipelineBuildEnvironment(t *testing.T) {
+
+	got := buildEnvironment(t, pipelineTemplate(t))
+
+	want := map[string]string{
+		"TENANT":                "acme",
+		"ENVIRONMENT":           "test",
+		"COST_CENTRE":           "cc-1234",
+		"SOURCE_REPO":           "https://github.com/org/repo",
+		"PIPELINE_EXECUTION_ID": "#{codepipeline.PipelineExecutionId}",
+		"COMMIT_DATE":           "#{SourceVariables.CommitterDate}",
+	}
+
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+
+	if _, ok := got["CDK_VPC_ENABLED"]; ok {
+		t.Error("CDK_VPC_ENABLED passed with the VPC disabled")
+	}
+}
+
+// buildEnvironment returns the synth action's variables, it passes them to
+// CodeBuild as a JSON string in the pipeline action configuration
+func buildEnvironment(t *testing.T, template assertions.Template) map[string]string {
+	t.Helper()
+
+	got := map[string]string{}
+
+	for _, pipeline := range resources(template, "AWS::CodePipeline::Pipeline") {
+		for _, stage := range pipeline["Properties"].(map[string]interface{})["Stages"].([]interface{}) {
+			for _, action := range stage.(map[string]interface{})["Actions"].([]interface{}) {
+
+				config, _ := action.(map[string]interface{})["Configuration"].(map[string]interface{})
+
+				raw, ok := config["EnvironmentVariables"].(string)
+				if !ok {
+					continue
+				}
+
+				var variables []struct{ Name, Type, Value string }
+				if err := json.Unmarshal([]byte(raw), &variables); err != nil {
+					t.Fatal(err)
+				}
+
+				for _, v := range variables {
+					got[v.Name] = v.Value
+				}
+			}
+		}
+	}
+
+	return got
+}
+
+func TestPipelineVpc(t *testing.T) {
+
+	ec2Actions := func(statements map[string]map[string]interface{}) []string {
+		actions := []string{}
+		for _, statement := range statements {
+			for _, action := range strs(statement["Action"]) {
+				if strings.HasPrefix(action, "ec2:") {
+					actions = append(actions, action)
+				}
+			}
+		}
+		return actions
+	}
+
+	template := pipelineTemplate(t)
+	for _, id := range []string{"PermissionsBoundary", "DeploymentBoundary", "ExecutionPolicy"} {
+		if actions := ec2Actions(policyStatements(template, id)); len(actions) > 0 {
+			t.Errorf("%s: grants %v with the VPC disabled", id, actions)
+		}
+	}
+
+	props := pipelineProps
+	props.Vpc = hosting.VpcProps{Enabled: true, Name: "shared", SubnetType: "isolated", Endpoints: true}
+	template = assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+
+	// the functions only need their network interfaces
+	if actions := ec2Actions(boundaryStatements(template)); asJSON(t, actions) != `["ec2:CreateNetworkInterface","ec2:DeleteNetworkInterface","ec2:Describe*"]` {
+		t.Errorf("PermissionsBoundary: grants %v with the VPC enabled", actions)
+	}
+
+	for _, id := range []string{"DeploymentBoundary", "ExecutionPolicy"} {
+		statements := policyStatements(template, id)
+
+		actions := ec2Actions(statements)
+		for _, action := range []string{"ec2:CreateNetworkInterface", "ec2:CreateSecurityGroup", "ec2:CreateVpcEndpoint", "ec2:ModifyVpcEndpoint", "ec2:*SecurityGroup*"} {
+			if !contains(actions, action) {
+				t.Errorf("%s: %s not granted with the VPC enabled", id, action)
+			}
+		}
+
+		// created with the tenant tag, tagged on create only, and changed
+		// when tagged with the tenant
+		for sid, want := range map[string]string{
+			"AllowVpcCreates":         `{"StringEquals":{"aws:RequestTag/Tenant":"acme"}}`,
+			"AllowVpcCreateTags":      `{"StringEquals":{"ec2:CreateAction":["CreateSecurityGroup","CreateVpcEndpoint"]}}`,
+			"AllowTenantVpcResources": `{"StringEquals":{"aws:ResourceTag/Tenant":"acme"}}`,
+		} {
+			if statement, ok := statements[sid]; !ok {
+				t.Errorf("%s: %s missing", id, sid)
+			} else if condition := asJSON(t, statement["Condition"]); condition != want {
+				t.Errorf("%s: %s condition is %s", id, sid, condition)
+			}
+		}
+	}
+
+	got := buildEnvironment(t, template)
+	for name, value := range map[string]string{
+		"CDK_VPC_ENABLED":     "true",
+		"CDK_VPC_NAME":        "shared",
+		"CDK_VPC_SUBNET_TYPE": "isolated",
+		"CDK_VPC_ENDPOINTS":   "true",
+	} {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+}
+
+// unconditional drops the resources a condition may leave out, e.g. the
+// bootstrap's own key
+func unconditional(found map[string]map[string]interface{}) map[string]map[string]interface{} {
+	for id, resource := range found {
+		if _, ok := resource["Condition"]; ok {
+			delete(found, id)
+		}
+	}
+	return found
+}
+
+func TestPipelineCreatedKey(t *testing.T) {
+
+	props := pipelineProps
+	props.CreateKmsKey = true
+
+	template := assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+	qualifier := util.CalculateQualifier(props.Tenant, props.Application)
+
+	keys := unconditional(resources(template, "AWS::KMS::Key"))
+	if len(keys) != 1 {
+		t.Fatalf("%d keys, want 1", len(keys))
+	}
+
+	var keyID string
+	for id, key := range keys {
+		keyID = id
+
+		properties := key["Properties"].(map[string]interface{})
+		if properties["EnableKeyRotation"] != true {
+			t.Error("key rotation is disabled")
+		}
+
+		policy := asJSON(t, properties["KeyPolicy"])
+		if strings.Contains(policy, `"kms:*"`) {
+			t.Errorf("key policy grants kms:* %s", policy)
+		}
+		if !strings.Contains(policy, ":role/cdk-"+qualifier+"-*") {
+			t.Errorf("key policy is not scoped to the qualifier roles %s", policy)
+		}
+	}
+
+	buckets := resources(template, "AWS::S3::Bucket")
+
+	staging, ok := buckets["StagingBucket"]
+	if !ok {
+		t.Fatal("StagingBucket: missing from the bootstrap")
+	}
+	if encryption := asJSON(t, staging["Properties"].(map[string]interface{})["BucketEncryption"]); !strings.Contains(encryption, `{"Ref":"`+keyID+`"}`) {
+		t.Errorf("StagingBucket: encryption is %s", encryption)
+	}
+
+	artifacts := 0
+	for id, bucket := range buckets {
+		if id != "StagingBucket" && strings.Contains(asJSON(t, bucket["Properties"].(map[string]interface{})["BucketEncryption"]), keyID) {
+			artifacts++
+		}
+	}
+	if artifacts != 1 {
+		t.Errorf("%d artifact buckets encrypted with the key, want 1", artifacts)
+	}
+
+	boundary := ""
+	for _, policy := range resources(template, "AWS::IAM::ManagedPolicy") {
+		boundary += asJSON(t, policy)
+	}
+
+	if strings.Contains(boundary, `"kms:*"`) {
+		t.Error("boundary still allows kms:*")
+	}
+
+	// the key's use is merged with the other tenant resources
+	tenant := asJSON(t, boundaryStatements(template)["AllowTenantResources"])
+	if !strings.Contains(tenant, `"kms:Decrypt"`) || !strings.Contains(tenant, `"PipelineKey`) {
+		t.Errorf("boundary does not allow the pipeline key: %s", tenant)
+	}
+}
+
+func TestPipelineImportedKey(t *testing.T) {
+
+	arn := "arn:aws:kms:eu-west-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+	props := pipelineProps
+	props.KmsKeyArn = arn
+
+	template := assertions.Template_FromStack(PipelineStack(newApp(t), "Pipeline", &props))
+
+	if keys := unconditional(resources(template, "AWS::KMS::Key")); len(keys) != 0 {
+		t.Errorf("%d keys created for an existing key", len(keys))
+	}
+
+	staging := resources(template, "AWS::S3::Bucket")["StagingBucket"]
+	if encryption := asJSON(t, staging["Properties"].(map[string]interface{})["BucketEncryption"]); !strings.Contains(encryption, `{"Fn::Sub":"1234abcd-12ab-34cd-56ef-1234567890ab"}`) {
+		t.Errorf("StagingBucket: encryption is %s", encryption)
+	}
+
+	tenant := boundaryStatements(template)["AllowTenantResources"]
+	if !contains(strs(tenant["Resource"]), arn) {
+		t.Errorf("boundary does not allow the imported key: %v", tenant["Resource"])
+	}
+}
+
+func TestPipelineSnapshot(t *testing.T) {
+	snapshot(t, pipelineTemplate(t), "pipeline")
+}
@@ -0,0 +1,72 @@
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+)
+
+// maxPolicySize is the IAM limit of a managed policy, white space excluded
+const maxPolicySize = 6144
+
+// account IDs are always 12 digits
+const sampleAccount = "123456789012"
+
+// renderPolicy renders the intrinsics of a synthesized policy document for a
+// sample account, region and key, as long as the deployed ones. policySize
+// needs it at synth time, the tests also read rendered ARNs through it.
+func renderPolicy(v interface{}) interface{} {
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["Ref"].(string); ok {
+			if ref == "AWS::AccountId" {
+				return sampleAccount
+			}
+			return "eu-west-1"
+		}
+		if join, ok := v["Fn::Join"].([]interface{}); ok {
+			parts := []string{}
+			for _, p := range join[1].([]interface{}) {
+				parts = append(parts, fmt.Sprint(renderPolicy(p)))
+			}
+			return strings.Join(parts, join[0].(string))
+		}
+		if sub, ok := v["Fn::Sub"].(string); ok {
+			return strings.ReplaceAll(sub, "${AWS::AccountId}", sampleAccount)
+		}
+		if _, ok := v["Fn::GetAtt"]; ok {
+			return "arn:aws:kms:eu-west-1:" + sampleAccount + ":key/00000000-0000-0000-0000-000000000000"
+		}
+		rendered := map[string]interface{}{}
+		for k, e := range v {
+			rendered[k] = renderPolicy(e)
+		}
+		return rendered
+
+	case []interface{}:
+		rendered := []interface{}{}
+		for _, e := range v {
+			rendered = append(rendered, renderPolicy(e))
+		}
+		return rendered
+	}
+
+	return v
+}
+
+// policySize is the size IAM counts of a managed policy's document, the
+// boundary grows with the guardrails and PassRole services
+func policySize(policy awsiam.ManagedPolicy) int {
+
+	document, err := json.Marshal(renderPolicy(awscdk.Stack_Of(policy).Resolve(policy.Document())))
+	if err != nil {
+		log.Fatal("Cannot render policy ", err)
+	}
+
+	return len(document)
+}
@@ -0,0 +1,193 @@
+package stacks
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/cxapi"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var update = flag.Bool("update", false, "rewrite the template snapshots in testdata")
+
+// tests run from the repository root, functions are discovered under
+// resources/ and the jsii runtime resolves relative paths from the
+// directory it started in
+const testdata = "pkg/stacks/testdata"
+
+// bootstrapTemplate stands in for cdk bootstrap --show-template
+var bootstrapTemplate = filepath.Join(testdata, "bootstrap-template.yaml")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	if err := os.Chdir("../.."); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(m.Run())
+}
+
+// newApp returns an app with the cdk.json feature flags the cdk CLI would
+// pass, skipping asset bundling as asset hashes are custom so templates do
+// not depend on the build
+func newApp(t *testing.T) awscdk.App {
+	t.Helper()
+
+	raw, err := ioutil.ReadFile("cdk.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Context map[string]interface{} `json:"context"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Context["aws:cdk:bundling-stacks"] = []string{}
+
+	return awscdk.NewApp(&awscdk.AppProps{
+		Outdir:             jsii.String(t.TempDir()),
+		AnalyticsReporting: jsii.Bool(false),
+		Context:            &config.Context,
+	})
+}
+
+// check runs a template assertion, which panics on a mismatch
+func check(t *testing.T, name string, assertion func()) {
+	t.Helper()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s: %v", name, r)
+		}
+	}()
+
+	assertion()
+}
+
+// resources returns the template resources of a type, by logical ID
+func resources(template assertions.Template, typ string) map[string]map[string]interface{} {
+
+	found := map[string]map[string]interface{}{}
+
+	for id, resource := range *template.FindResources(jsii.String(typ), nil) {
+		found[id] = *resource
+	}
+
+	return found
+}
+
+// synthErrors returns the error annotations of a stack, as path: message
+func synthErrors(stack awscdk.Stack) []string {
+
+	errs := []string{}
+
+	assembly := awscdk.Stage_Of(stack).Synth(nil)
+	for _, message := range *assembly.GetStackArtifact(stack.ArtifactId()).Messages() {
+		if message.Level == cxapi.SynthesisMessageLevel_ERROR {
+			errs = append(errs, fmt.Sprintf("%s: %v", *message.Id, message.Entry.Data))
+		}
+	}
+
+	return errs
+}
+
+// asJSON renders a template fragment for substring checks
+func asJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(raw)
+}
+
+// asset hashes follow the function source and commit, not the stack, and
+// appear in parameter names along with a logical ID suffix derived from them
+var (
+	assetParameter = regexp.MustCompile(`AssetParameters[0-9a-f]{64}(ArtifactHash|S3Bucket|S3VersionKey)[0-9A-F]{8}`)
+	assetHash      = regexp.MustCompile(`[0-9a-f]{64}`)
+)
+
+// snapshot compares a template with testdata/<name>.template.json
+func snapshot(t *testing.T, template assertions.Template, name string) {
+	t.Helper()
+
+	raw, err := json.MarshalIndent(template.ToJSON(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := assetParameter.ReplaceAll(raw, []byte("AssetParametersASSET$1"))
+	got = assetHash.ReplaceAll(got, []byte("ASSET"))
+	got = append(got, '\n')
+
+	golden := filepath.Join(testdata, name+".template.json")
+
+	if *update {
+		if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%s, run go test -update to create it", err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("%s differs from the synthesized template, review and run go test ./pkg/stacks -update\n%s", golden, diffLines(string(want), string(got)))
+	}
+}
+
+// diffLines is a minimal line diff, enough to spot a snapshot change
+func diffLines(want, got string) string {
+
+	w, g := splitLines(want), splitLines(got)
+
+	out := ""
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var a, b string
+		if i < len(w) {
+			a = w[i]
+		}
+		if i < len(g) {
+			b = g[i]
+		}
+		if a != b {
+			out += fmt.Sprintf("line %d\n- %s\n+ %s\n", i+1, a, b)
+			if len(out) > 2000 {
+				return out + "..."
+			}
+		}
+	}
+
+	return out
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i, c := range s {
+		if c == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(lines, s[start:])
+}
@@ -0,0 +1,210 @@
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"permission-boundary-pipeline-cdk/pkg/util"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/jsii-runtime-go"
+	_jsii_ "github.com/aws/jsii-runtime-go/runtime"
+)
+
+// logRetentionProvider is the logical ID prefix of the function the CDK
+// creates for log retention, a raw resource without tag support
+const logRetentionProvider = "LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8a"
+
+// bucketNotificationsHandler is the logical ID prefix of the function the
+// CDK creates for S3 bucket notifications, a raw resource the tag aspects
+// tag but whose tags and name jsii cannot read
+const bucketNotificationsHandler = "BucketNotificationsHandler050a0587b7544547bf325f094a3db834"
+
+// nameProperties are the physical name properties the boundary scopes by,
+// names CloudFormation generates start with the stack name instead
+var nameProperties = map[string]string{
+	"AWS::CloudWatch::Alarm":      "AlarmName",
+	"AWS::CloudWatch::Dashboard":  "DashboardName",
+	"AWS::DynamoDB::Table":        "TableName",
+	"AWS::Events::Rule":           "Name",
+	"AWS::IAM::Role":              "RoleName",
+	"AWS::Lambda::Function":       "FunctionName",
+	"AWS::Logs::LogGroup":         "LogGroupName",
+	"AWS::S3::Bucket":             "BucketName",
+	"AWS::SNS::Topic":             "TopicName",
+	"AWS::SQS::Queue":             "QueueName",
+	"AWS::SecretsManager::Secret": "Name",
+}
+
+// untaggedTypes cannot carry tags in this CDK version, they belong to a
+// tagged parent or are scoped by name alone
+var untaggedTypes = map[string]bool{
+	"AWS::ApiGatewayV2::Integration":        true,
+	"AWS::ApiGatewayV2::Route":              true,
+	"AWS::CloudWatch::Alarm":                true,
+	"AWS::CloudWatch::Dashboard":            true,
+	"AWS::EC2::SecurityGroupIngress":        true,
+	"AWS::EC2::VPCEndpoint":                 true,
+	"AWS::Events::Rule":                     true,
+	"AWS::IAM::Policy":                      true,
+	"AWS::Lambda::EventSourceMapping":       true,
+	"AWS::Lambda::Permission":               true,
+	"AWS::Logs::LogGroup":                   true,
+	"AWS::S3::BucketPolicy":                 true,
+	"AWS::SNS::Subscription":                true,
+	"AWS::SNS::TopicPolicy":                 true,
+	"AWS::SQS::QueuePolicy":                 true,
+	"AWS::SecretsManager::ResourcePolicy":   true,
+	"AWS::SecretsManager::RotationSchedule": true,
+	"Custom::LogRetention":                  true,
+	"Custom::S3BucketNotifications":         true,
+}
+
+// mandatoryTags are the tags of every application resource
+func mandatoryTags(naming util.Naming, costCentre, version string) map[string]string {
+	return map[string]string{
+		util.TenantTag: naming.Tenant,
+		"Environment":  naming.Environment,
+		"Application":  naming.Application,
+		"Qualifier":    naming.Qualifier,
+		"CostCentre":   costCentre,
+		"Version":      version,
+	}
+}
+
+// sortedKeys returns the tag keys in a stable order
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyTags adds a tag aspect per mandatory tag, which tags every taggable
+// resource in scope. Empty values are a configuration error.
+func applyTags(scope awscdk.IConstruct, tags map[string]string) error {
+
+	missing := []string{}
+	for _, k := range sortedKeys(tags) {
+		if tags[k] == "" {
+			missing = append(missing, k)
+			continue
+		}
+		awscdk.Tags_Of(scope).Add(jsii.String(k), jsii.String(tags[k]), nil)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no value for the mandatory tags %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// conventions is an aspect which fails synthesis for resources the
+// permissions boundary would not cover: untaggable types which are not
+// scoped otherwise, missing or foreign mandatory tags and names outside
+// the naming prefix. It has to run after the tag aspects.
+type conventions struct {
+	naming util.Naming
+	tags   map[string]string
+}
+
+// Visit reports the errors as annotations, which the cdk cli refuses to
+// deploy. The jsii runtime hands the resources the CDK creates to Go as
+// plain constructs, their type, tags and name are read by property. It
+// also reuses proxies of other types, so the stack must not take constructs
+// back as IDependable, e.g. from AddToPrincipalPolicy.
+func (c *conventions) Visit(node awscdk.IConstruct) {
+
+	if !*awscdk.CfnResource_IsCfnResource(node) {
+		return
+	}
+
+	var typ *string
+	_jsii_.Get(node, "cfnResourceType", &typ)
+
+	// the CDK's own functions are raw resources, reading their tags or
+	// name panics
+	switch *node.Node().Scope().Node().Id() {
+	case logRetentionProvider, bucketNotificationsHandler:
+		return
+	}
+
+	var present map[string]string
+	if *awscdk.TagManager_IsTaggable(node) {
+		var tags awscdk.TagManager
+		_jsii_.Get(node, "tags", &tags)
+
+		present = map[string]string{}
+		for k, v := range *tags.TagValues() {
+			present[k] = *v
+		}
+	}
+
+	var name interface{}
+	if property, ok := nameProperties[*typ]; ok {
+		var value interface{}
+		_jsii_.Get(node, strings.ToLower(property[:1])+property[1:], &value)
+		name = awscdk.Stack_Of(node).Resolve(value)
+	}
+
+	for _, err := range checkResource(c.naming, c.tags, *typ, present, name) {
+		awscdk.Annotations_Of(node).AddError(jsii.String(err.Error()))
+	}
+}
+
+// checkResource checks the tags, nil for untaggable resources, and the
+// explicit name of a resource
+func checkResource(naming util.Naming, tags map[string]string, typ string, present map[string]string, name interface{}) []error {
+
+	errs := []error{}
+
+	switch {
+	case untaggedTypes[typ]:
+	case present == nil:
+		errs = append(errs, fmt.Errorf("%s cannot be tagged, add it to untaggedTypes if its name scopes it", typ))
+	default:
+		for _, k := range sortedKeys(tags) {
+			if present[k] != tags[k] {
+				errs = append(errs, fmt.Errorf("%s tag %s is %q, want %q", typ, k, present[k], tags[k]))
+			}
+		}
+	}
+
+	if property, ok := nameProperties[typ]; ok {
+		if err := checkName(naming, typ, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s %s", typ, property, err))
+		}
+	}
+
+	return errs
+}
+
+// checkName checks an explicit name starts with the naming prefix. Names
+// which are not strings refer to other, themselves checked, resources.
+func checkName(naming util.Naming, typ string, value interface{}) error {
+
+	name, ok := value.(string)
+	if !ok {
+		return nil
+	}
+
+	prefix := naming.Prefix()
+	switch {
+	case typ == "AWS::S3::Bucket":
+		prefix = naming.BucketPrefix()
+	case typ == "AWS::SecretsManager::Secret":
+		prefix = naming.PathPrefix()
+	case typ == "AWS::Logs::LogGroup" && strings.HasPrefix(name, "/aws/lambda/"):
+		prefix = "/aws/lambda/" + prefix
+	}
+
+	if !strings.HasPrefix(name, prefix) {
+		return fmt.Errorf("%s does not start with %s", name, prefix)
+	}
+
+	return nil
+}
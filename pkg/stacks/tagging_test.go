@@ -0,0 +1,121 @@
+package stacks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/awssqs"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// tagValues reads the list and the map form of a Tags property
+func tagValues(tags interface{}) map[string]string {
+
+	values := map[string]string{}
+
+	switch tags := tags.(type) {
+	case []interface{}:
+		for _, t := range tags {
+			tag, _ := t.(map[string]interface{})
+			key, _ := tag["Key"].(string)
+			value, _ := tag["Value"].(string)
+			values[key] = value
+		}
+	case map[string]interface{}:
+		for key, v := range tags {
+			value, _ := v.(string)
+			values[key] = value
+		}
+	}
+
+	return values
+}
+
+func TestCheckResource(t *testing.T) {
+
+	wrongTenant := mandatoryTags(testNaming, "cc-1234", "1.0.0")
+	wrongTenant["Tenant"] = "other"
+
+	tests := []struct {
+		name    string
+		typ     string
+		present map[string]string
+		value   interface{}
+		want    string
+	}{
+		{"tagged", "AWS::SNS::Topic", testTags, nil, ""},
+		{"untagged type", "AWS::Lambda::Permission", nil, nil, ""},
+		{"generated name", "AWS::Lambda::Function", testTags, map[string]interface{}{"Ref": "Name"}, ""},
+		{"conventional name", "AWS::S3::Bucket", testTags, "acme-test-uploads", ""},
+		{"lambda log group", "AWS::Logs::LogGroup", nil, "/aws/lambda/Acme-Test-Api", ""},
+		{"not taggable", "AWS::Example::Thing", nil, nil, "cannot be tagged"},
+		{"missing tag", "AWS::SQS::Queue", map[string]string{"Tenant": "acme"}, nil, "tag Application"},
+		{"other tenant", "AWS::IAM::Role", wrongTenant, nil, `tag Tenant is "other"`},
+		{"unconventional name", "AWS::Lambda::Function", testTags, "OtherTestApi", "does not start with Acme-Test-"},
+		{"bucket case", "AWS::S3::Bucket", testTags, "Acme-Test-uploads", "does not start with acme-test-"},
+		{"longer environment", "AWS::Lambda::Function", testTags, "Acme-Testing-Api", "does not start with Acme-Test-"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			errs := checkResource(testNaming, testTags, test.typ, test.present, test.value)
+
+			if test.want == "" {
+				for _, err := range errs {
+					t.Error(err)
+				}
+				return
+			}
+
+			if len(errs) == 0 || !strings.Contains(errs[0].Error(), test.want) {
+				t.Errorf("errors %v, want %q", errs, test.want)
+			}
+		})
+	}
+}
+
+func TestConventions(t *testing.T) {
+
+	stack := awscdk.NewStack(newApp(t), jsii.String("Conventions"), nil)
+	if err := applyTags(stack, testTags); err != nil {
+		t.Fatal(err)
+	}
+	awscdk.Aspects_Of(stack).Add(&conventions{naming: testNaming, tags: testTags})
+
+	awssqs.NewQueue(stack, jsii.String("Queue"), &awssqs.QueueProps{QueueName: jsii.String("Acme-Test-Queue")})
+	awssqs.NewQueue(stack, jsii.String("Unconventional"), &awssqs.QueueProps{QueueName: jsii.String("Other-Queue")})
+	awscdk.NewCfnResource(stack, jsii.String("Thing"), &awscdk.CfnResourceProps{Type: jsii.String("AWS::Example::Thing")})
+	awslambda.NewFunction(stack, jsii.String("Function"), &awslambda.FunctionProps{
+		Runtime:      awslambda.Runtime_NODEJS_14_X(),
+		Handler:      jsii.String("index.handler"),
+		Code:         awslambda.Code_FromInline(jsii.String("exports.handler = async () => {}")),
+		LogRetention: awslogs.RetentionDays_ONE_WEEK,
+	})
+
+	errs := strings.Join(synthErrors(stack), "\n")
+
+	for _, want := range []string{
+		"/Conventions/Unconventional/Resource: AWS::SQS::Queue QueueName Other-Queue does not start with Acme-Test-",
+		"/Conventions/Thing: AWS::Example::Thing cannot be tagged",
+	} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("errors\n%s\nwant %s", errs, want)
+		}
+	}
+
+	if n := strings.Count(errs, "\n") + 1; n != 2 {
+		t.Errorf("%d errors, want 2:\n%s", n, errs)
+	}
+}
+
+func TestApplyTagsMissing(t *testing.T) {
+
+	err := applyTags(newApp(t), mandatoryTags(testNaming, "", "1.0.0"))
+	if err == nil || !strings.Contains(err.Error(), "CostCentre") {
+		t.Errorf("error %v, want missing CostCentre", err)
+	}
+}
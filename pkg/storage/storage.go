@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-cdk-go/awscdk"
+	"github.com/aws/aws-cdk-go/awscdk/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/awslambda"
+	"github.com/aws/jsii-runtime-go"
+
+	"github.com/aws/constructs-go/constructs/v3"
+)
+
+// ttlAttribute matches repository.TTLAttribute in resources/api
+const ttlAttribute = "ttl"
+
+// TableProps configures the application table, keys follow the single
+// table layout used by resources/api/pkg/repository
+type TableProps struct {
+	Enabled             bool    `envconfig:"ENABLED" default:"false"`
+	PointInTimeRecovery bool    `envconfig:"PITR" default:"true"`
+	GlobalIndexes       float64 `envconfig:"GSIS" default:"1"`
+	// retain the table when the stack is deleted
+	Retain bool `envconfig:"RETAIN" default:"true"`
+}
+
+// TableStack provisions the application table, PK/SK keys with GSI<n>PK and
+// GSI<n>SK indexes, on demand billing and TTL expiry
+func TableStack(scope constructs.Construct, id string, props *TableProps) awsdynamodb.Table {
+
+	removalPolicy := awscdk.RemovalPolicy_DESTROY
+	if props.Retain {
+		removalPolicy = awscdk.RemovalPolicy_RETAIN
+	}
+
+	table := awsdynamodb.NewTable(scope, &id, &awsdynamodb.TableProps{
+		PartitionKey: &awsdynamodb.Attribute{
+			Name: jsii.String("PK"),
+			Type: awsdynamodb.AttributeType_STRING,
+		},
+		SortKey: &awsdynamodb.Attribute{
+			Name: jsii.String("SK"),
+			Type: awsdynamodb.AttributeType_STRING,
+		},
+		BillingMode:         awsdynamodb.BillingMode_PAY_PER_REQUEST,
+		Encryption:          awsdynamodb.TableEncryption_AWS_MANAGED,
+		PointInTimeRecovery: jsii.Bool(props.PointInTimeRecovery),
+		TimeToLiveAttribute: jsii.String(ttlAttribute),
+		RemovalPolicy:       removalPolicy,
+	})
+
+	for i := 1; i <= int(props.GlobalIndexes); i++ {
+		index := fmt.Sprintf("GSI%d", i)
+
+		table.AddGlobalSecondaryIndex(&awsdynamodb.GlobalSecondaryIndexProps{
+			IndexName: jsii.String(index),
+			PartitionKey: &awsdynamodb.Attribute{
+				Name: jsii.String(index + "PK"),
+				Type: awsdynamodb.AttributeType_STRING,
+			},
+			SortKey: &awsdynamodb.Attribute{
+				Name: jsii.String(index + "SK"),
+				Type: awsdynamodb.AttributeType_STRING,
+			},
+			ProjectionType: awsdynamodb.ProjectionType_ALL,
+		})
+	}
+
+	return table
+}
+
+// GrantReadWriteItems grants the item level access the repository needs, no
+// scans and no table management. DescribeTable backs the readiness check.
+func GrantReadWriteItems(table awsdynamodb.ITable, function awslambda.IFunction) {
+
+	function.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+		Sid:    jsii.String("PermitTableItems"),
+		Effect: awsiam.Effect_ALLOW,
+		Actions: jsii.Strings(
+			"dynamodb:BatchGetItem",
+			"dynamodb:BatchWriteItem",
+			"dynamodb:ConditionCheckItem",
+			"dynamodb:DeleteItem",
+			"dynamodb:DescribeTable",
+			"dynamodb:GetItem",
+			"dynamodb:PutItem",
+			"dynamodb:Query",
+			"dynamodb:UpdateItem",
+		),
+		Resources: &[]*string{
+			table.TableArn(),
+			jsii.String(*table.TableArn() + "/index/*"),
+		},
+	}))
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TenantTag is the tag key naming the tenant that owns a resource
+const TenantTag = "Tenant"
+
+// Naming is the naming convention of a tenant's application resources, the
+// permissions boundary only covers names it generates
+type Naming struct {
+	Tenant      string
+	Environment string
+	Application string
+	Qualifier   string
+}
+
+var namePart = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// Validate checks the tenant and environment hold no delimiter, so one
+// tenant's prefix never starts another's
+func (n Naming) Validate() error {
+
+	for field, value := range map[string]string{"tenant": n.Tenant, "environment": n.Environment} {
+		if !namePart.MatchString(value) {
+			return fmt.Errorf("%s %q must be lower case letters and digits", field, value)
+		}
+	}
+
+	return nil
+}
+
+// Prefix starts the names of the tenant's resources e.g. Acme-Staging-. Names
+// CloudFormation generates start with the stack name, so with the stack
+// name too. The trailing delimiter keeps tenant acme from matching the
+// resources of tenant acmecorp.
+func (n Naming) Prefix() string {
+	return fmt.Sprintf("%s-%s-", strings.Title(n.Tenant), strings.Title(n.Environment))
+}
+
+// BucketPrefix is Prefix for S3, which generates lower case bucket names
+// e.g. acme-staging-
+func (n Naming) BucketPrefix() string {
+	return strings.ToLower(n.Prefix())
+}
+
+// ApplicationStackName is the name of the tenant's application stack
+func (n Naming) ApplicationStackName() string {
+	return n.Prefix() + "ApplicationStack"
+}
+
+// PathPrefix holds the tenant's SSM parameters and secrets e.g. acme/staging/
+func (n Naming) PathPrefix() string {
+	return fmt.Sprintf("%s/%s/", n.Tenant, n.Environment)
+}
+
+// ParameterPath is the application's SSM parameter path e.g. /acme/staging/app
+func (n Naming) ParameterPath() string {
+	return "/" + n.PathPrefix() + n.Application
+}
@@ -0,0 +1,42 @@
+package util
+
+import "testing"
+
+func TestNamingPrefix(t *testing.T) {
+
+	naming := Naming{Tenant: "acme", Environment: "staging"}
+
+	if prefix := naming.Prefix(); prefix != "Acme-Staging-" {
+		t.Errorf("Prefix() = %q", prefix)
+	}
+	if prefix := naming.BucketPrefix(); prefix != "acme-staging-" {
+		t.Errorf("BucketPrefix() = %q", prefix)
+	}
+	if name := naming.ApplicationStackName(); name != "Acme-Staging-ApplicationStack" {
+		t.Errorf("ApplicationStackName() = %q", name)
+	}
+}
+
+func TestNamingValidate(t *testing.T) {
+
+	for _, naming := range []Naming{
+		{Tenant: "acme", Environment: "staging"},
+		{Tenant: "acme2", Environment: "prod"},
+	} {
+		if err := naming.Validate(); err != nil {
+			t.Errorf("%+v: %v", naming, err)
+		}
+	}
+
+	// a delimiter would let one tenant's prefix start another's
+	for _, naming := range []Naming{
+		{Tenant: "acme-test", Environment: "staging"},
+		{Tenant: "acme", Environment: "test-staging"},
+		{Tenant: "Acme", Environment: "staging"},
+		{Tenant: "", Environment: "staging"},
+	} {
+		if err := naming.Validate(); err == nil {
+			t.Errorf("%+v: valid", naming)
+		}
+	}
+}
@@ -2,70 +2,71 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"api/internal/api"
 	"api/internal/config"
+	"api/internal/handler"
 
 	"api/pkg/log"
-	"api/pkg/log/chilogger"
-	"api/pkg/version"
 
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-xray-sdk-go/xray"
-	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
-	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/render"
 	"go.uber.org/zap"
 )
 
-// our router
-var chiLambda *chiadapter.ChiLambda
-
-func init() {
+// serveLocal serves the router over plain http until interrupted
+func serveLocal(addr string, configFile string) {
 
 	logger := log.Logger(context.TODO())
 
-	// stdout and stderr are sent to AWS CloudWatch Logs
-	logger.Warn("lambda cold start")
-
-	r := chi.NewRouter()
+	if configFile != "" {
+		if err := config.LoadFile(configFile); err != nil {
+			logger.Fatal("unable to load config file", zap.String("file", configFile), zap.Error(err))
+		}
+		log.SetLevel(os.Getenv("LOG_LEVEL"))
+	}
 
-	// various middlewares
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
-	r.Use(chilogger.Logger())
-	r.Use(render.SetContentType(render.ContentTypeJSON))
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler.LocalHandler(),
+	}
 
-	r.Get("/version", version.Handler)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	r.Get("/hello", api.HelloWorldHandler)
-
-	chiLambda = chiadapter.New(r)
-}
+	go func() {
+		<-ctx.Done()
 
-// Handler is
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	logger := log.LoggerWithLambdaRqID(ctx)
+		srv.Shutdown(shutdownCtx)
+	}()
 
-	xray.SetLogger(&log.XrayLogger{})
+	logger.Info("serving locally", zap.String("addr", addr))
 
-	xray.Configure(xray.Config{
-		LogLevel:       "warn",
-		ServiceVersion: version.Version,
-	})
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal("local server failed", zap.Error(err))
+	}
+}
 
-	logger.Info("application handler")
+func main() {
 
-	logger.Debug("recieved event", zap.Reflect("req", req))
+	local := flag.Bool("local", false, "serve over http rather than as a lambda")
+	addr := flag.String("addr", "localhost:8080", "listen address when serving locally")
+	configFile := flag.String("config", "", "KEY=VALUE env file to read when serving locally")
 
-	vctx := config.ReadEnvConfig(ctx, "APPLICATION")
+	flag.Parse()
 
-	return chiLambda.ProxyWithContext(vctx, req)
-}
+	if *local || !handler.OnLambda() {
+		serveLocal(*addr, *configFile)
+		return
+	}
 
-func main() {
-	lambda.Start(Handler)
+	lambda.Start(handler.Handler)
 }
@@ -0,0 +1,334 @@
+// Replay runs captured APIGatewayProxyRequest events through the real
+// lambda Handler, events can be raw requests, JSONL streams of requests or
+// the "recieved event" debug log lines emitted by the Handler
+//
+//	go run ./cmd/replay testdata/replay/*.json
+//	go run ./cmd/replay -golden testdata/replay/*.json
+//	go run ./cmd/replay -golden -update testdata/replay/*.json
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"api/internal/handler"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var (
+	golden   = flag.Bool("golden", false, "compare responses with <event file>.golden")
+	update   = flag.Bool("update", false, "with -golden, rewrite the .golden files")
+	function = flag.String("function", "api", "function name for the synthetic lambda context")
+	region   = flag.String("region", "eu-west-1", "region for the synthetic lambda context")
+	timeout  = flag.Duration("timeout", 30*time.Second, "invocation deadline for the synthetic lambda context")
+	verbose  = flag.Bool("v", false, "show handler logs and metrics on stderr")
+	ignored  stringsFlag
+)
+
+func main() {
+
+	flag.Var(&ignored, "ignore-header", "response header to ignore when comparing, repeatable")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: replay [flags] event.json|events.jsonl ...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	// keep stdout for responses
+	if *verbose {
+		log.SetOutput(os.Stderr)
+		metrics.SetOutput(os.Stderr)
+	} else {
+		log.SetOutput(ioutil.Discard)
+		metrics.SetOutput(ioutil.Discard)
+	}
+
+	lambdacontext.FunctionName = *function
+
+	failed := false
+
+	for _, file := range flag.Args() {
+
+		ok, err := replayFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(2)
+		}
+
+		if !ok {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// replayFile runs every event in a file, returning false on a golden mismatch
+func replayFile(file string) (bool, error) {
+
+	reqs, err := readEvents(file)
+	if err != nil {
+		return false, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+	var resps []events.APIGatewayProxyResponse
+
+	for i, req := range reqs {
+
+		resp, err := invoke(fmt.Sprintf("replay-%s-%d", base, i), req)
+		if err != nil {
+			return false, fmt.Errorf("event %d: handler error: %s", i, err)
+		}
+
+		resps = append(resps, normalise(resp))
+	}
+
+	if !*golden {
+		return true, writeResponses(os.Stdout, resps)
+	}
+
+	goldenFile := file + ".golden"
+
+	if *update {
+		var buf bytes.Buffer
+		if err := writeResponses(&buf, resps); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(os.Stderr, "updated %s\n", goldenFile)
+		return true, ioutil.WriteFile(goldenFile, buf.Bytes(), 0644)
+	}
+
+	expected, err := readResponses(goldenFile)
+	if err != nil {
+		return false, err
+	}
+
+	ok := true
+
+	if len(expected) != len(resps) {
+		fmt.Printf("FAIL %s: %d responses, golden has %d\n", file, len(resps), len(expected))
+		return false, nil
+	}
+
+	for i := range resps {
+
+		want := normalise(expected[i])
+
+		if reflect.DeepEqual(want, resps[i]) {
+			continue
+		}
+
+		ok = false
+
+		fmt.Printf("FAIL %s: event %d\n", file, i)
+		fmt.Print(diff(indent(want), indent(resps[i])))
+	}
+
+	if ok {
+		fmt.Printf("ok   %s: %d events\n", file, len(resps))
+	}
+
+	return ok, nil
+}
+
+// invoke runs a single event through the Handler with a synthetic lambda context
+func invoke(fallbackID string, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	requestID := req.RequestContext.RequestID
+	if requestID == "" {
+		requestID = fallbackID
+	}
+
+	lc := &lambdacontext.LambdaContext{
+		AwsRequestID:       requestID,
+		InvokedFunctionArn: fmt.Sprintf("arn:aws:lambda:%s:000000000000:function:%s", *region, *function),
+	}
+
+	ctx, cancel := context.WithTimeout(lambdacontext.NewContext(context.Background(), lc), *timeout)
+	defer cancel()
+
+	return handler.Handler(ctx, req)
+}
+
+// readEvents decodes one or more events from a file
+func readEvents(file string) ([]events.APIGatewayProxyRequest, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var reqs []events.APIGatewayProxyRequest
+
+	dec := json.NewDecoder(f)
+
+	for {
+		var raw json.RawMessage
+
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		// a captured "recieved event" log line carries the request under req
+		var logLine struct {
+			Msg string          `json:"msg"`
+			Req json.RawMessage `json:"req"`
+		}
+
+		if err := json.Unmarshal(raw, &logLine); err == nil && logLine.Msg != "" && logLine.Req != nil {
+			raw = logLine.Req
+		}
+
+		var req events.APIGatewayProxyRequest
+
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, fmt.Errorf("event %d: %s", len(reqs), err)
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	return reqs, nil
+}
+
+func readResponses(file string) ([]events.APIGatewayProxyResponse, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var resps []events.APIGatewayProxyResponse
+
+	dec := json.NewDecoder(f)
+
+	for {
+		var resp events.APIGatewayProxyResponse
+
+		if err := dec.Decode(&resp); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		resps = append(resps, resp)
+	}
+
+	return resps, nil
+}
+
+func writeResponses(w io.Writer, resps []events.APIGatewayProxyResponse) error {
+
+	for _, resp := range resps {
+		if _, err := fmt.Fprintln(w, indent(resp)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalise drops ignored headers and empty maps so responses compare cleanly
+func normalise(resp events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+
+	for _, h := range ignored {
+		delete(resp.Headers, h)
+		delete(resp.MultiValueHeaders, h)
+	}
+
+	if len(resp.Headers) == 0 {
+		resp.Headers = nil
+	}
+
+	if len(resp.MultiValueHeaders) == 0 {
+		resp.MultiValueHeaders = nil
+	}
+
+	return resp
+}
+
+func indent(v interface{}) string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
+
+// diff is a minimal line based diff of two strings
+func diff(a, b string) string {
+
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	// longest common subsequence table
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			fmt.Fprintf(&out, "  %s\n", x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", x[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", y[j])
+			j++
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,24 @@
+// Rotate is the Secrets Manager rotation function of the secrets the hosting
+// stack creates, which deploys it along with their rotation schedules. It
+// has no manifest, it is not an application function.
+package main
+
+import (
+	"api/pkg/log"
+	"api/pkg/secrets"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+func main() {
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		LogLevel: log.AWSLevel(),
+		Logger:   &log.AWSLogger{},
+	}))
+
+	lambda.Start(secrets.NewRotator(secretsmanager.New(sess)).Rotate)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsConfig is the browser access policy, under lambda API Gateway applies
+// the same policy so this is only needed when serving locally
+type CorsConfig struct {
+	// allowed origins, * for any, empty disables CORS
+	Origins       []string
+	Methods       []string
+	Headers       []string
+	ExposeHeaders []string
+	Credentials   bool
+	MaxAge        time.Duration
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for an origin,
+// empty when it is not allowed
+func (c CorsConfig) allowOrigin(origin string) string {
+
+	for _, o := range c.Origins {
+		switch {
+		case o == "*" && !c.Credentials:
+			return "*"
+		case strings.EqualFold(o, origin):
+			return origin
+		}
+	}
+
+	return ""
+}
+
+func (c CorsConfig) allowMethod(method string) bool {
+
+	for _, m := range c.Methods {
+		if strings.EqualFold(m, method) || m == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CorsMiddleware answers preflight requests and adds CORS headers to
+// requests from allowed origins. It wraps the router as preflight OPTIONS
+// requests match no route.
+func CorsMiddleware(cfg func(ctx context.Context) CorsConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c := cfg(r.Context())
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+
+			allow := c.allowOrigin(origin)
+
+			if allow == "" {
+				if preflight {
+					RenderError(w, r, ErrForbidden("origin not allowed"))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+
+			h.Set("Access-Control-Allow-Origin", allow)
+
+			if c.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(c.ExposeHeaders) != 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if method := r.Header.Get("Access-Control-Request-Method"); !c.allowMethod(method) {
+				RenderError(w, r, NewError(KindMethodNotAllowed, "method not allowed", nil))
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(c.Methods, ", "))
+
+			if len(c.Headers) != 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(c.Headers, ", "))
+			}
+
+			if c.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api/pkg/log"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestCors(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+
+	r := chi.NewRouter()
+	r.Get("/hello", HelloWorldHandler)
+
+	srv := httptest.NewServer(CorsMiddleware(func(ctx context.Context) CorsConfig {
+		return CorsConfig{
+			Origins:       []string{"https://app.example.com"},
+			Methods:       []string{"GET", "PUT"},
+			Headers:       []string{"Authorization", "Content-Type"},
+			ExposeHeaders: []string{"Retry-After"},
+			Credentials:   true,
+			MaxAge:        10 * time.Minute,
+		}
+	})(r))
+	defer srv.Close()
+
+	request := func(method, origin, requestMethod string) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, srv.URL+"/hello", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if requestMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", requestMethod)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp
+	}
+
+	resp := request(http.MethodOptions, "https://app.example.com", "PUT")
+	if resp.StatusCode != http.StatusNoContent ||
+		resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		resp.Header.Get("Access-Control-Allow-Methods") != "GET, PUT" ||
+		resp.Header.Get("Access-Control-Allow-Credentials") != "true" ||
+		resp.Header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("preflight: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if resp := request(http.MethodOptions, "https://app.example.com", "DELETE"); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("preflight for a disallowed method: %d", resp.StatusCode)
+	}
+
+	if resp := request(http.MethodOptions, "https://evil.example.com", "GET"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("preflight from a disallowed origin: %d", resp.StatusCode)
+	}
+
+	resp = request(http.MethodGet, "https://app.example.com", "")
+	if resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		resp.Header.Get("Access-Control-Expose-Headers") != "Retry-After" {
+		t.Fatalf("simple request: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	resp = request(http.MethodGet, "https://evil.example.com", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: %d %v", resp.StatusCode, resp.Header)
+	}
+}
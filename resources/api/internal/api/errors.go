@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies a domain error so it can be mapped onto a HTTP status
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindMethodNotAllowed
+	KindConflict
+	KindPrecondition
+	KindTooManyRequests
+	KindUnavailable
+)
+
+var kindStatus = map[Kind]int{
+	KindInternal:         http.StatusInternalServerError,
+	KindInvalid:          http.StatusBadRequest,
+	KindUnauthorized:     http.StatusUnauthorized,
+	KindForbidden:        http.StatusForbidden,
+	KindNotFound:         http.StatusNotFound,
+	KindMethodNotAllowed: http.StatusMethodNotAllowed,
+	KindConflict:         http.StatusConflict,
+	KindPrecondition:     http.StatusPreconditionFailed,
+	KindTooManyRequests:  http.StatusTooManyRequests,
+	KindUnavailable:      http.StatusServiceUnavailable,
+}
+
+var kindSlug = map[Kind]string{
+	KindInternal:         "internal",
+	KindInvalid:          "invalid-request",
+	KindUnauthorized:     "unauthorized",
+	KindForbidden:        "forbidden",
+	KindNotFound:         "not-found",
+	KindMethodNotAllowed: "method-not-allowed",
+	KindConflict:         "conflict",
+	KindPrecondition:     "precondition-failed",
+	KindTooManyRequests:  "too-many-requests",
+	KindUnavailable:      "unavailable",
+}
+
+// Status returns the HTTP status code for the kind
+func (k Kind) Status() int {
+	if status, ok := kindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func (k Kind) String() string {
+	if slug, ok := kindSlug[k]; ok {
+		return slug
+	}
+	return kindSlug[KindInternal]
+}
+
+// Error is a typed domain error, Message is safe to return to the caller
+// whereas Err is the low-level cause and is only ever logged
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError returns a domain error
+func NewError(kind Kind, message string, err error) *Error {
+	return &Error{
+		Kind:    kind,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// KindOf returns the kind of the first domain error in the chain, anything
+// else is treated as internal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// StatusOf returns the HTTP status code for an error
+func StatusOf(err error) int {
+	return KindOf(err).Status()
+}
+
+func ErrInvalidRequest(err error) *Error {
+	return NewError(KindInvalid, err.Error(), err)
+}
+
+func ErrUnauthorized(message string) *Error {
+	return NewError(KindUnauthorized, message, nil)
+}
+
+func ErrForbidden(message string) *Error {
+	return NewError(KindForbidden, message, nil)
+}
+
+func ErrNotFound(message string) *Error {
+	return NewError(KindNotFound, message, nil)
+}
+
+func ErrConflict(message string, err error) *Error {
+	return NewError(KindConflict, message, err)
+}
+
+func ErrPreconditionFailed(message string, err error) *Error {
+	return NewError(KindPrecondition, message, err)
+}
+
+func ErrTooManyRequests(message string) *Error {
+	return NewError(KindTooManyRequests, message, nil)
+}
+
+func ErrUnavailable(message string, err error) *Error {
+	return NewError(KindUnavailable, message, err)
+}
+
+func ErrInternal(err error) *Error {
+	return NewError(KindInternal, "internal server error", err)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestKindStatus(t *testing.T) {
+
+	for kind, want := range map[Kind]struct {
+		status int
+		slug   string
+	}{
+		KindInternal:         {http.StatusInternalServerError, "internal"},
+		KindInvalid:          {http.StatusBadRequest, "invalid-request"},
+		KindUnauthorized:     {http.StatusUnauthorized, "unauthorized"},
+		KindForbidden:        {http.StatusForbidden, "forbidden"},
+		KindNotFound:         {http.StatusNotFound, "not-found"},
+		KindMethodNotAllowed: {http.StatusMethodNotAllowed, "method-not-allowed"},
+		KindConflict:         {http.StatusConflict, "conflict"},
+		KindPrecondition:     {http.StatusPreconditionFailed, "precondition-failed"},
+		KindTooManyRequests:  {http.StatusTooManyRequests, "too-many-requests"},
+		KindUnavailable:      {http.StatusServiceUnavailable, "unavailable"},
+		Kind(99):             {http.StatusInternalServerError, "internal"},
+	} {
+		if got := kind.Status(); got != want.status {
+			t.Errorf("%s: status %d, want %d", want.slug, got, want.status)
+		}
+		if got := kind.String(); got != want.slug {
+			t.Errorf("%d: is %s, want %s", kind, got, want.slug)
+		}
+	}
+}
+
+func TestKindOf(t *testing.T) {
+
+	cause := errors.New("ConditionalCheckFailedException")
+
+	for _, test := range []struct {
+		err    error
+		kind   Kind
+		status int
+	}{
+		{ErrConflict("note changed", cause), KindConflict, http.StatusConflict},
+		{fmt.Errorf("saving: %w", ErrNotFound("no note")), KindNotFound, http.StatusNotFound},
+		{cause, KindInternal, http.StatusInternalServerError},
+		{nil, KindInternal, http.StatusInternalServerError},
+	} {
+		if got := KindOf(test.err); got != test.kind {
+			t.Errorf("%v: kind %s, want %s", test.err, got, test.kind)
+		}
+		if got := StatusOf(test.err); got != test.status {
+			t.Errorf("%v: status %d, want %d", test.err, got, test.status)
+		}
+	}
+
+	err := ErrConflict("note changed", cause)
+	if !errors.Is(err, cause) {
+		t.Error("the cause is not unwrapped")
+	}
+	if got := err.Error(); got != "note changed: ConditionalCheckFailedException" {
+		t.Errorf("Error() is %q", got)
+	}
+}
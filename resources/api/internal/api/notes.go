@@ -0,0 +1,279 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+	"api/pkg/repository"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+const (
+	noteType = "note"
+
+	defaultNotesLimit = 20
+	maxNotesLimit     = 100
+	maxNoteBody       = 4096
+)
+
+var noteID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Note is a stored note, the notes collection is listed via GSI1. Keys
+// start with the request tenant so tenants never share items.
+type Note struct {
+	repository.Item
+	ID        string    `dynamodbav:"ID" json:"id"`
+	Body      string    `dynamodbav:"Body" json:"body"`
+	UpdatedAt time.Time `dynamodbav:"UpdatedAt" json:"updatedAt"`
+}
+
+func (n *Note) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// tenantPrefix scopes keys to the tenant e.g. TENANT#acme#
+func tenantPrefix(tenant string) string {
+	return "TENANT#" + tenant + "#"
+}
+
+// NoteKey returns the primary key of a tenant's note
+func NoteKey(tenant, id string) repository.Key {
+	return repository.Key{PK: tenantPrefix(tenant) + "NOTE#" + id, SK: "NOTE"}
+}
+
+// notesCollection is the GSI1 partition listing a tenant's notes
+func notesCollection(tenant string) string {
+	return tenantPrefix(tenant) + "NOTES"
+}
+
+// NewNote returns a tenant's note with its keys populated
+func NewNote(tenant, id string) *Note {
+	key := NoteKey(tenant, id)
+
+	return &Note{
+		Item: repository.Item{
+			PK:     key.PK,
+			SK:     key.SK,
+			GSI1PK: notesCollection(tenant),
+			GSI1SK: id,
+			Type:   noteType,
+		},
+		ID: id,
+	}
+}
+
+type notePutRequest struct {
+	Body string `json:"body"`
+	// 0 creates, otherwise the version being replaced
+	Version int64 `json:"version"`
+}
+
+type notesResponse struct {
+	Notes []Note `json:"notes"`
+	Next  string `json:"next,omitempty"`
+}
+
+func (n *notesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// notes serves the notes resource from a repository
+type notes struct {
+	repo repository.Repository
+}
+
+// NotesRouter returns the notes resource, mounted at /notes
+func NotesRouter(repo repository.Repository) http.Handler {
+
+	n := &notes{repo: repo}
+
+	r := chi.NewRouter()
+
+	r.NotFound(NotFoundHandler)
+	r.MethodNotAllowed(MethodNotAllowedHandler)
+
+	r.Get("/", n.list)
+	r.Get("/{id}", n.get)
+	r.Put("/{id}", n.put)
+	r.Delete("/{id}", n.delete)
+
+	return r
+}
+
+// id validates the {id} URL parameter
+func (n *notes) id(r *http.Request) (string, error) {
+	id := chi.URLParam(r, "id")
+	if !noteID.MatchString(id) {
+		return "", ErrInvalidRequest(fmt.Errorf("note id must match %s", noteID))
+	}
+	return id, nil
+}
+
+func (n *notes) list(w http.ResponseWriter, r *http.Request) {
+
+	limit := int64(defaultNotesLimit)
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || l < 1 || l > maxNotesLimit {
+			RenderError(w, r, ErrInvalidRequest(fmt.Errorf("limit must be between 1 and %d", maxNotesLimit)))
+			return
+		}
+		limit = l
+	}
+
+	var found []Note
+
+	page, err := n.repo.Query(r.Context(), repository.Query{
+		Index:  "GSI1",
+		PK:     notesCollection(log.Tenant(r.Context())),
+		Limit:  limit,
+		Cursor: r.URL.Query().Get("cursor"),
+	}, &found)
+
+	if err != nil {
+		RenderError(w, r, storageError(err))
+		return
+	}
+
+	if found == nil {
+		found = []Note{}
+	}
+
+	render.Render(w, r, &notesResponse{
+		Notes: found,
+		Next:  page.Next,
+	})
+}
+
+func (n *notes) get(w http.ResponseWriter, r *http.Request) {
+
+	id, err := n.id(r)
+	if err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	note := &Note{}
+
+	if err := n.repo.Get(r.Context(), NoteKey(log.Tenant(r.Context()), id), note); err != nil {
+		RenderError(w, r, storageError(err))
+		return
+	}
+
+	render.Render(w, r, note)
+}
+
+func (n *notes) put(w http.ResponseWriter, r *http.Request) {
+
+	id, err := n.id(r)
+	if err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	var req notePutRequest
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		RenderError(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if req.Body == "" || len(req.Body) > maxNoteBody {
+		RenderError(w, r, ErrInvalidRequest(fmt.Errorf("body must be 1 to %d bytes", maxNoteBody)))
+		return
+	}
+
+	note := NewNote(log.Tenant(r.Context()), id)
+	note.Body = req.Body
+	note.Version = req.Version
+	note.UpdatedAt = time.Now().UTC()
+
+	// optimistic locking, 0 creates
+	cond := repository.IfVersion
+	if req.Version == 0 {
+		cond = repository.IfNotExists
+	}
+
+	if err := n.repo.Put(r.Context(), note, cond); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrConditionFailed) && cond == repository.IfNotExists:
+			RenderError(w, r, ErrConflict("note already exists", err))
+		case errors.Is(err, repository.ErrConditionFailed):
+			RenderError(w, r, ErrPreconditionFailed("note version has changed", err))
+		default:
+			RenderError(w, r, storageError(err))
+		}
+		return
+	}
+
+	log.LoggerWithLambdaRqID(r.Context()).Debug("note saved", zap.String("id", id), zap.Int64("version", note.Version))
+
+	metrics.Count(r.Context(), "NoteSaved")
+
+	if cond == repository.IfNotExists {
+		render.Status(r, http.StatusCreated)
+	}
+
+	render.Render(w, r, note)
+}
+
+func (n *notes) delete(w http.ResponseWriter, r *http.Request) {
+
+	id, err := n.id(r)
+	if err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	note := NewNote(log.Tenant(r.Context()), id)
+
+	// delete a specific version with ?version=
+	cond := repository.IfExists
+
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || version < 1 {
+			RenderError(w, r, ErrInvalidRequest(fmt.Errorf("version must be a positive integer")))
+			return
+		}
+		note.Version = version
+		cond = repository.IfVersion
+	}
+
+	if err := n.repo.Delete(r.Context(), note, cond); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrConditionFailed) && cond == repository.IfExists:
+			RenderError(w, r, ErrNotFound("note not found"))
+		case errors.Is(err, repository.ErrConditionFailed):
+			RenderError(w, r, ErrPreconditionFailed("note version has changed", err))
+		default:
+			RenderError(w, r, storageError(err))
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// storageError maps repository errors onto domain errors
+func storageError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrNotFound("note not found")
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return ErrInvalidRequest(err)
+	case errors.Is(err, repository.ErrNoTable):
+		return ErrUnavailable("storage is not configured", err)
+	}
+	return ErrInternal(err)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+	"api/pkg/repository"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newNotesServer(t *testing.T) (*httptest.Server, *repository.Memory) {
+	t.Helper()
+
+	log.SetOutput(ioutil.Discard)
+	metrics.SetOutput(ioutil.Discard)
+
+	repo := repository.NewMemory()
+
+	r := chi.NewRouter()
+	r.Mount("/notes", NotesRouter(repo))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv, repo
+}
+
+func do(t *testing.T, method, url, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+
+	return resp.StatusCode, out
+}
+
+func TestNotesLifecycle(t *testing.T) {
+
+	srv, repo := newNotesServer(t)
+
+	status, note := do(t, http.MethodPut, srv.URL+"/notes/a", `{"body":"first"}`)
+	if status != http.StatusCreated || note["version"] != 1.0 {
+		t.Fatalf("create: %d %v", status, note)
+	}
+
+	if status, _ := do(t, http.MethodPut, srv.URL+"/notes/a", `{"body":"again"}`); status != http.StatusConflict {
+		t.Fatalf("duplicate create: %d", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, srv.URL+"/notes/a", `{"body":"stale","version":7}`); status != http.StatusPreconditionFailed {
+		t.Fatalf("stale update: %d", status)
+	}
+
+	status, note = do(t, http.MethodPut, srv.URL+"/notes/a", `{"body":"second","version":1}`)
+	if status != http.StatusOK || note["version"] != 2.0 {
+		t.Fatalf("update: %d %v", status, note)
+	}
+
+	status, note = do(t, http.MethodGet, srv.URL+"/notes/a", "")
+	if status != http.StatusOK || note["body"] != "second" || note["id"] != "a" {
+		t.Fatalf("get: %d %v", status, note)
+	}
+
+	if status, _ := do(t, http.MethodDelete, srv.URL+"/notes/a?version=1", ""); status != http.StatusPreconditionFailed {
+		t.Fatalf("stale delete: %d", status)
+	}
+
+	if status, _ := do(t, http.MethodDelete, srv.URL+"/notes/a", ""); status != http.StatusNoContent {
+		t.Fatalf("delete: %d", status)
+	}
+
+	if status, _ := do(t, http.MethodGet, srv.URL+"/notes/a", ""); status != http.StatusNotFound {
+		t.Fatalf("get deleted: %d", status)
+	}
+
+	if status, _ := do(t, http.MethodDelete, srv.URL+"/notes/a", ""); status != http.StatusNotFound {
+		t.Fatalf("delete missing: %d", status)
+	}
+
+	if repo.Len() != 0 {
+		t.Fatalf("%d items left", repo.Len())
+	}
+}
+
+func TestNotesValidation(t *testing.T) {
+
+	srv, _ := newNotesServer(t)
+
+	cases := []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/notes/bad%20id", `{"body":"x"}`},
+		{http.MethodPut, "/notes/a", `{"body":""}`},
+		{http.MethodPut, "/notes/a", `not json`},
+		{http.MethodGet, "/notes?limit=0", ""},
+		{http.MethodGet, "/notes?cursor=bogus", ""},
+		{http.MethodDelete, "/notes/a?version=x", ""},
+	}
+
+	for _, c := range cases {
+		status, problem := do(t, c.method, srv.URL+c.path, c.body)
+		if status != http.StatusBadRequest || problem["type"] != "urn:problem-type:invalid-request" {
+			t.Errorf("%s %s: %d %v", c.method, c.path, status, problem)
+		}
+	}
+}
+
+func TestNotesList(t *testing.T) {
+
+	srv, _ := newNotesServer(t)
+
+	for _, id := range []string{"c", "a", "b"} {
+		if status, _ := do(t, http.MethodPut, srv.URL+"/notes/"+id, `{"body":"`+id+`"}`); status != http.StatusCreated {
+			t.Fatalf("create %s: %d", id, status)
+		}
+	}
+
+	var ids []string
+	url := srv.URL + "/notes?limit=2"
+
+	for i := 0; i < 3; i++ {
+		status, page := do(t, http.MethodGet, url, "")
+		if status != http.StatusOK {
+			t.Fatalf("list: %d %v", status, page)
+		}
+
+		for _, n := range page["notes"].([]interface{}) {
+			ids = append(ids, n.(map[string]interface{})["id"].(string))
+		}
+
+		next, _ := page["next"].(string)
+		if next == "" {
+			break
+		}
+		url = srv.URL + "/notes?limit=2&cursor=" + next
+	}
+
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Fatalf("ids = %v", ids)
+	}
+}
+
+func TestNotesNoTable(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+
+	r := chi.NewRouter()
+	r.Mount("/notes", NotesRouter(repository.NewDynamoDB(nil, func(ctx context.Context) string { return "" })))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	if status, _ := do(t, http.MethodGet, srv.URL+"/notes/a", ""); status != http.StatusServiceUnavailable {
+		t.Fatalf("no table: %d", status)
+	}
+}
+
+func TestNotesTenantIsolation(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+	metrics.SetOutput(ioutil.Discard)
+
+	repo := repository.NewMemory()
+
+	r := chi.NewRouter()
+	r.Use(TenantMiddleware(func(context.Context) TenantConfig {
+		return TenantConfig{Allowed: []string{"acme", "globex"}}
+	}))
+	r.Mount("/notes", NotesRouter(repo))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	acme, globex := srv.URL+"/t/acme/notes", srv.URL+"/t/globex/notes"
+
+	if status, _ := do(t, http.MethodPut, acme+"/a", `{"body":"acme's"}`); status != http.StatusCreated {
+		t.Fatalf("acme create: %d", status)
+	}
+
+	// the same id is another note for another tenant
+	if status, _ := do(t, http.MethodGet, globex+"/a", ""); status != http.StatusNotFound {
+		t.Errorf("globex read acme's note: %d", status)
+	}
+	if status, _ := do(t, http.MethodDelete, globex+"/a", ""); status != http.StatusNotFound {
+		t.Errorf("globex deleted acme's note: %d", status)
+	}
+	if status, _ := do(t, http.MethodPut, globex+"/a", `{"body":"globex's","version":1}`); status != http.StatusPreconditionFailed {
+		t.Errorf("globex overwrote acme's note: %d", status)
+	}
+
+	if status, page := do(t, http.MethodGet, globex, ""); status != http.StatusOK || len(page["notes"].([]interface{})) != 0 {
+		t.Errorf("globex listed %d %v", status, page)
+	}
+
+	status, note := do(t, http.MethodGet, acme+"/a", "")
+	if status != http.StatusOK || note["body"] != "acme's" || note["version"] != 1.0 {
+		t.Errorf("acme's note after globex's requests: %d %v", status, note)
+	}
+
+	if status, page := do(t, http.MethodGet, acme, ""); status != http.StatusOK || len(page["notes"].([]interface{})) != 1 {
+		t.Errorf("acme listed %d %v", status, page)
+	}
+
+	if status, _ := do(t, http.MethodGet, srv.URL+"/t/initech/notes", ""); status != http.StatusForbidden {
+		t.Errorf("unlisted tenant: %d", status)
+	}
+}
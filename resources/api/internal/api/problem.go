@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"api/pkg/log"
+
+	"github.com/aws/aws-xray-sdk-go/header"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"go.uber.org/zap"
+)
+
+// ContentTypeProblemJSON is the RFC 7807 media type
+const ContentTypeProblemJSON = "application/problem+json"
+
+// prefix for problem type URIs
+const problemTypeBase = "urn:problem-type:"
+
+// Problem is an RFC 7807 problem details response, extended with the
+// identifiers needed to find the matching logs and traces
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	TraceID   string `json:"traceId,omitempty"`
+}
+
+// NewProblem builds a problem response from an error, the detail of
+// non domain and internal errors is withheld from the caller
+func NewProblem(r *http.Request, err error) *Problem {
+
+	kind := KindOf(err)
+
+	p := &Problem{
+		Type:      problemTypeBase + kind.String(),
+		Title:     http.StatusText(kind.Status()),
+		Status:    kind.Status(),
+		Instance:  r.URL.Path,
+		RequestID: log.RequestID(r.Context()),
+		TraceID:   traceID(r),
+	}
+
+	var e *Error
+	if errors.As(err, &e) && kind != KindInternal {
+		p.Detail = e.Message
+	}
+
+	return p
+}
+
+// RenderError logs an error and writes it as an application/problem+json response
+func RenderError(w http.ResponseWriter, r *http.Request, err error) {
+
+	p := NewProblem(r, err)
+
+	logger := log.LoggerWithLambdaRqID(r.Context()).With(
+		zap.Error(err),
+		zap.Int("status", p.Status),
+		zap.String("problem", p.Type),
+		zap.String("traceID", p.TraceID),
+	)
+
+	if p.Status >= http.StatusInternalServerError {
+		logger.Error("request failed")
+	} else {
+		logger.Warn("request rejected")
+	}
+
+	WriteProblem(w, p)
+}
+
+// WriteProblem writes a problem response
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+
+	w.Header().Set("Content-Type", ContentTypeProblemJSON)
+	w.WriteHeader(p.Status)
+
+	json.NewEncoder(w).Encode(p)
+}
+
+// NotFoundHandler renders a problem for unmatched routes
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	RenderError(w, r, ErrNotFound("no route matches "+r.URL.Path))
+}
+
+// MethodNotAllowedHandler renders a problem for unsupported methods
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	RenderError(w, r, NewError(KindMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path, nil))
+}
+
+// traceID returns the X-Ray trace root, either forwarded by API Gateway or
+// from the lambda invocation
+func traceID(r *http.Request) string {
+
+	traceHeader := r.Header.Get(xray.TraceIDHeaderKey)
+
+	if traceHeader == "" {
+		traceHeader, _ = r.Context().Value(xray.LambdaTraceHeaderKey).(string)
+	}
+
+	if traceHeader == "" {
+		traceHeader = os.Getenv("_X_AMZN_TRACE_ID")
+	}
+
+	if traceHeader == "" {
+		return ""
+	}
+
+	return header.FromString(traceHeader).TraceID
+}
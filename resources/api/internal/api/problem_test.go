@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api/pkg/log"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+)
+
+func TestNewProblem(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+
+	for _, test := range []struct {
+		err    error
+		status int
+		typ    string
+		detail string
+	}{
+		{ErrNotFound("no note 1"), http.StatusNotFound, "urn:problem-type:not-found", "no note 1"},
+		{ErrConflict("note changed", errors.New("ConditionalCheckFailedException")), http.StatusConflict, "urn:problem-type:conflict", "note changed"},
+		// internal detail is withheld, domain or not
+		{ErrInternal(errors.New("dial tcp 10.0.0.1:443")), http.StatusInternalServerError, "urn:problem-type:internal", ""},
+		{errors.New("dial tcp 10.0.0.1:443"), http.StatusInternalServerError, "urn:problem-type:internal", ""},
+	} {
+		p := NewProblem(r, test.err)
+
+		if p.Status != test.status || p.Type != test.typ || p.Detail != test.detail {
+			t.Errorf("%v: problem is %d %s %q, want %d %s %q", test.err, p.Status, p.Type, p.Detail, test.status, test.typ, test.detail)
+		}
+		if p.Title != http.StatusText(test.status) || p.Instance != "/notes/1" {
+			t.Errorf("%v: title %q, instance %q", test.err, p.Title, p.Instance)
+		}
+	}
+}
+
+func TestProblemCorrelation(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	r = r.WithContext(log.WithRqID(r.Context(), "rq-1"))
+	r.Header.Set(xray.TraceIDHeaderKey, "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+
+	p := NewProblem(r, ErrNotFound("no note 1"))
+	if p.RequestID != "rq-1" || p.TraceID != "1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("requestId %q, traceId %q", p.RequestID, p.TraceID)
+	}
+
+	// or the trace of the lambda invocation
+	r = httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	r = r.WithContext(context.WithValue(r.Context(), xray.LambdaTraceHeaderKey, "Root=1-5759e988-00000000000000000000000a;Parent=53995c3f42cd8ad8;Sampled=1"))
+
+	if p := NewProblem(r, ErrNotFound("no note 1")); p.TraceID != "1-5759e988-00000000000000000000000a" {
+		t.Errorf("traceId %q", p.TraceID)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+
+	r := httptest.NewRequest(http.MethodGet, "/notes/1", nil)
+	r = r.WithContext(log.WithRqID(r.Context(), "rq-1"))
+
+	w := httptest.NewRecorder()
+	RenderError(w, r, ErrForbidden("not your note"))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if got := w.Header().Get("Content-Type"); got != ContentTypeProblemJSON {
+		t.Errorf("Content-Type is %s, want %s", got, ContentTypeProblemJSON)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusForbidden || p.Detail != "not your note" || p.RequestID != "rq-1" {
+		t.Errorf("problem is %+v", p)
+	}
+}
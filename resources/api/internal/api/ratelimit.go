@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+	"api/pkg/ratelimit"
+
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+)
+
+// RateLimitConfig holds the per caller quotas, a zero Default disables
+// limiting for tenants without their own quota
+type RateLimitConfig struct {
+	Default ratelimit.Quota
+	Tenants map[string]ratelimit.Quota
+}
+
+// quota returns the quota applying to a tenant
+func (c RateLimitConfig) quota(tenant string) ratelimit.Quota {
+	if q, ok := c.Tenants[tenant]; ok {
+		return q
+	}
+	return c.Default
+}
+
+// RateLimitMiddleware applies an in-process token bucket per tenant and
+// caller, callers over quota get a 429 with Retry-After. It runs after
+// TenantMiddleware so each tenant is limited against its own quota.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, cfg func(ctx context.Context) RateLimitConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			tenant := log.Tenant(r.Context())
+
+			q := cfg(r.Context()).quota(tenant)
+
+			if ok, wait := limiter.Allow(tenant+"/"+callerIdentity(r), q); !ok {
+
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+
+				metrics.Count(r.Context(), "Throttled")
+
+				RenderError(w, r, ErrTooManyRequests("rate limit exceeded"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// callerIdentity is the authorizer principal when there is one, else the
+// client address
+func callerIdentity(r *http.Request) string {
+
+	if apigw, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok {
+
+		if sub := authorizerClaim(r.Context(), "sub"); sub != "" {
+			return "sub:" + sub
+		}
+
+		if principal, _ := apigw.Authorizer["principalId"].(string); principal != "" {
+			return "principal:" + principal
+		}
+
+		if apigw.Identity.SourceIP != "" {
+			return "ip:" + apigw.Identity.SourceIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
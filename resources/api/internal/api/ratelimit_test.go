@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+	"api/pkg/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRateLimit(t *testing.T) {
+
+	log.SetOutput(ioutil.Discard)
+	metrics.SetOutput(ioutil.Discard)
+
+	limiter := ratelimit.New()
+	limiter.Now = func() time.Time { return time.Unix(1000, 0) }
+
+	r := chi.NewRouter()
+	r.Use(TenantMiddleware(func(ctx context.Context) TenantConfig {
+		return TenantConfig{Default: "acme", Allowed: []string{"acme", "globex"}}
+	}))
+	r.Use(RateLimitMiddleware(limiter, func(ctx context.Context) RateLimitConfig {
+		return RateLimitConfig{
+			Default: ratelimit.Quota{Rate: 0.5, Burst: 2},
+			Tenants: map[string]ratelimit.Quota{"globex": {}},
+		}
+	}))
+	r.Get("/hello", HelloWorldHandler)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		if status, _ := do(t, http.MethodGet, srv.URL+"/hello", ""); status != http.StatusOK {
+			t.Fatalf("request %d: %d", i, status)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "2" {
+		t.Fatalf("over quota: %d Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// unlimited tenant
+	for i := 0; i < 5; i++ {
+		if status, _ := do(t, http.MethodGet, srv.URL+"/t/globex/hello", ""); status != http.StatusOK {
+			t.Fatalf("globex request %d: %d", i, status)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"api/pkg/log"
+
+	"go.uber.org/zap"
+)
+
+// Recoverer is a middleware that recovers from panics, logs the panic (and a
+// backtrace), and returns a problem response with a 500 status in place of
+// middleware.Recoverer's plain text body.
+func Recoverer(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+
+				// let the server abort the response as intended
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+
+				log.LoggerWithLambdaRqID(r.Context()).Error("panic recovered",
+					zap.Any("panic", rvr),
+					zap.ByteString("stack", debug.Stack()),
+				)
+
+				RenderError(w, r, ErrInternal(fmt.Errorf("panic: %v", rvr)))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
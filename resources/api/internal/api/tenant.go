@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"go.uber.org/zap"
+)
+
+// TenantPathPrefix selects a tenant by path e.g. /t/acme/notes
+const TenantPathPrefix = "/t/"
+
+var tenantID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantConfig controls how tenants are resolved and which are served
+type TenantConfig struct {
+	// served when the request names no tenant, empty requires one
+	Default string
+	// tenants served, empty serves only Default
+	Allowed []string
+	// resolve <tenant>.<Domain> host names, empty disables
+	Domain string
+	// authorizer claim carrying the tenant, empty disables
+	Claim string
+}
+
+// allowed reports whether the tenant is served
+func (c TenantConfig) allowed(tenant string) bool {
+
+	if len(c.Allowed) == 0 {
+		return tenant == c.Default
+	}
+
+	for _, t := range c.Allowed {
+		if strings.TrimSpace(t) == tenant {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TenantMiddleware resolves the tenant from the host name, the authorizer
+// claims and the path prefix, which must agree when more than one is present.
+// The tenant is placed in the context next to the request ID, added as the
+// EMF Tenant dimension and annotated on the X-Ray trace. Without a tenant or
+// default the request passes through untouched, single tenant deployments
+// need no configuration.
+func TenantMiddleware(cfg func(ctx context.Context) TenantConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+
+			c := cfg(r.Context())
+
+			tenant, path, err := resolveTenant(c, r)
+			if err != nil {
+				RenderError(w, r, err)
+				return
+			}
+
+			if tenant == "" {
+				tenant = c.Default
+			}
+
+			if tenant == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !c.allowed(tenant) {
+				RenderError(w, r, NewError(KindForbidden, "tenant not served", fmt.Errorf("tenant %q", tenant)))
+				return
+			}
+
+			ctx := log.WithTenant(r.Context(), tenant)
+
+			metrics.Dimension(ctx, "Tenant", tenant)
+
+			// annotations need a subsegment, the lambda segment is read only
+			if ctx.Value(xray.LambdaTraceHeaderKey) != nil || xray.GetSegment(ctx) != nil {
+				var seg *xray.Segment
+				ctx, seg = xray.BeginSubsegment(ctx, "tenant")
+				if seg != nil {
+					if err := seg.AddAnnotation("tenant", tenant); err != nil {
+						log.Logger(ctx).Debug("unable to annotate trace", zap.Error(err))
+					}
+					defer seg.Close(nil)
+				}
+			}
+
+			r = r.WithContext(ctx)
+
+			if path != r.URL.Path {
+				u := *r.URL
+				u.Path, u.RawPath = path, ""
+				r.URL = &u
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// resolveTenant returns the tenant named by the request and the path with
+// any tenant prefix stripped, so routes match as usual
+func resolveTenant(c TenantConfig, r *http.Request) (string, string, error) {
+
+	var found []string
+
+	path := r.URL.Path
+
+	if c.Domain != "" {
+		host := strings.ToLower(r.Host)
+		if i := strings.LastIndex(host, ":"); i > strings.LastIndex(host, "]") {
+			host = host[:i]
+		}
+		if sub := strings.TrimSuffix(host, "."+strings.ToLower(c.Domain)); sub != host && !strings.Contains(sub, ".") {
+			found = append(found, sub)
+		}
+	}
+
+	if c.Claim != "" {
+		if claim := authorizerClaim(r.Context(), c.Claim); claim != "" {
+			found = append(found, claim)
+		}
+	}
+
+	if strings.HasPrefix(r.URL.Path, TenantPathPrefix) {
+		rest := strings.TrimPrefix(r.URL.Path, TenantPathPrefix)
+
+		tenant := rest
+		path = "/"
+		if i := strings.Index(rest, "/"); i >= 0 {
+			tenant, path = rest[:i], rest[i:]
+		}
+
+		found = append(found, tenant)
+	}
+
+	tenant := ""
+
+	for _, t := range found {
+		if !tenantID.MatchString(t) {
+			return "", "", ErrInvalidRequest(fmt.Errorf("tenant must match %s", tenantID))
+		}
+		if tenant != "" && t != tenant {
+			return "", "", ErrForbidden("request names more than one tenant")
+		}
+		tenant = t
+	}
+
+	return tenant, path, nil
+}
+
+// authorizerClaim reads a claim verified by the API Gateway authorizer, a
+// bearer token is never decoded here as its signature is not checked
+func authorizerClaim(ctx context.Context, name string) string {
+
+	apigw, ok := core.GetAPIGatewayContextFromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	claims, _ := apigw.Authorizer["claims"].(map[string]interface{})
+
+	// HTTP API JWT authorizers nest the claims
+	if jwt, ok := apigw.Authorizer["jwt"].(map[string]interface{}); ok && claims == nil {
+		claims, _ = jwt["claims"].(map[string]interface{})
+	}
+
+	claim, _ := claims[name].(string)
+
+	return claim
+}
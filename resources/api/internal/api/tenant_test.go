@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api/pkg/log"
+	"api/pkg/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func newTenantServer(t *testing.T, c TenantConfig) (*httptest.Server, *metrics.Logger) {
+	t.Helper()
+
+	log.SetOutput(ioutil.Discard)
+	metrics.SetOutput(ioutil.Discard)
+
+	m := metrics.New()
+
+	r := chi.NewRouter()
+
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(metrics.WithMetrics(r.Context(), m)))
+		})
+	})
+	r.Use(TenantMiddleware(func(ctx context.Context) TenantConfig { return c }))
+
+	r.Get("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, map[string]string{"tenant": log.Tenant(r.Context())})
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv, m
+}
+
+func whoami(t *testing.T, url, host string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = host
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out map[string]interface{}
+	render.DecodeJSON(resp.Body, &out)
+
+	return resp.StatusCode, out
+}
+
+func TestTenantResolution(t *testing.T) {
+
+	srv, m := newTenantServer(t, TenantConfig{
+		Default: "acme",
+		Allowed: []string{"acme", "globex"},
+		Domain:  "api.example.com",
+	})
+
+	cases := []struct {
+		path, host string
+		status     int
+		tenant     string
+	}{
+		{"/whoami", "", http.StatusOK, "acme"},
+		{"/whoami", "globex.api.example.com", http.StatusOK, "globex"},
+		{"/whoami", "GLOBEX.api.example.com:443", http.StatusOK, "globex"},
+		{"/t/globex/whoami", "", http.StatusOK, "globex"},
+		{"/t/globex/whoami", "globex.api.example.com", http.StatusOK, "globex"},
+		{"/t/acme/whoami", "globex.api.example.com", http.StatusForbidden, ""},
+		{"/t/initech/whoami", "", http.StatusForbidden, ""},
+		{"/whoami", "a.b.api.example.com", http.StatusOK, "acme"},
+		{"/t/Bad_Tenant/whoami", "", http.StatusBadRequest, ""},
+	}
+
+	for _, c := range cases {
+		status, body := whoami(t, srv.URL+c.path, c.host)
+		if status != c.status {
+			t.Errorf("%s %s: status %d, want %d", c.host, c.path, status, c.status)
+			continue
+		}
+		if c.status == http.StatusOK && body["tenant"] != c.tenant {
+			t.Errorf("%s %s: tenant %v, want %s", c.host, c.path, body["tenant"], c.tenant)
+		}
+	}
+
+	whoami(t, srv.URL+"/t/globex/whoami", "")
+
+	if got := m.Dimension("Tenant"); got != "globex" {
+		t.Fatalf("Tenant dimension = %q, want globex", got)
+	}
+}
+
+func TestTenantUnconfigured(t *testing.T) {
+
+	srv, _ := newTenantServer(t, TenantConfig{})
+
+	status, body := whoami(t, srv.URL+"/whoami", "")
+	if status != http.StatusOK || body["tenant"] != "" {
+		t.Fatalf("no tenant: %d %v", status, body)
+	}
+}
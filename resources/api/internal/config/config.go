@@ -1,20 +1,48 @@
 package config
 
 import (
+	"api/pkg/cors"
 	"api/pkg/log"
+	"bufio"
 	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/kelseyhightower/envconfig"
 	"go.uber.org/zap"
 )
 
 type ApiConfig struct {
-	LogLevel string `envconfig:"LOG_LEVEL" default:"INFO"`
+	LogLevel  string `envconfig:"LOG_LEVEL" default:"INFO"`
+	SSMPath   string `envconfig:"SSM_PATH"`
+	TableName string `envconfig:"TABLE_NAME"`
+	// seconds a secret version is served before it is read again
+	SecretTTL int `envconfig:"SECRET_TTL" default:"300"`
+
+	// the deployment tenant, served when a request names no tenant
+	Tenant string `envconfig:"TENANT"`
+	// tenants this api serves, defaults to the deployment tenant
+	Tenants []string `envconfig:"TENANTS"`
+	// resolve <tenant>.<TenantDomain> host names
+	TenantDomain string `envconfig:"TENANT_DOMAIN"`
+	// authorizer claim carrying the tenant
+	TenantClaim string `envconfig:"TENANT_CLAIM" default:"tenant"`
+
+	// per caller requests per second, 0 disables in-process limiting
+	RateLimit float64 `envconfig:"RATE_LIMIT" default:"0"`
+	RateBurst int     `envconfig:"RATE_BURST" default:"0"`
+	// per tenant overrides e.g. acme:10/20 as rate/burst
+	TenantRateLimits map[string]string `envconfig:"TENANT_RATE_LIMITS"`
+
+	Environment string `envconfig:"ENVIRONMENT" default:"development"`
+
+	// browser access when serving locally, API Gateway applies the
+	// deployed policy from the same settings
+	Cors cors.Policy `envconfig:"CORS"`
 }
 
-// our config
-var apiConfig ApiConfig
-
 // The key type is unexported to prevent collisions with context keys defined in
 // other packages.
 type contextKey string
@@ -28,18 +56,26 @@ var (
 	contextKeyConfig = contextKey("config")
 )
 
-// ReadEnvConfig is
-func ReadEnvConfig(ctx context.Context, namespace string) context.Context {
+// Process reads the environment into a new ApiConfig, callers share it
+// read only
+func Process(ctx context.Context, namespace string) *ApiConfig {
 
 	logger := log.Logger(ctx)
 
-	err := envconfig.Process(namespace, &apiConfig)
+	apiConfig := &ApiConfig{}
+
+	err := envconfig.Process(namespace, apiConfig)
 
 	if err != nil {
 		logger.Panic("unable to process environment", zap.Error(err))
 	}
 
-	return context.WithValue(ctx, contextKeyConfig, &apiConfig)
+	return apiConfig
+}
+
+// ReadEnvConfig places a freshly read config in the context
+func ReadEnvConfig(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, contextKeyConfig, Process(ctx, namespace))
 }
 
 // GetConfig is
@@ -55,3 +91,54 @@ func GetConfig(ctx context.Context) *ApiConfig {
 
 	return econfig
 }
+
+// LoadFile reads KEY=VALUE pairs from a local env file into the process
+// environment, variables already set in the environment take precedence
+func LoadFile(path string) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(strings.TrimPrefix(text, "export "), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+
+		if _, ok := os.LookupEnv(key); !ok {
+			os.Setenv(key, value)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Middleware places the config in the request context, used when serving
+// locally rather than via the lambda Handler. The config is read once, as
+// requests are served concurrently.
+func Middleware(namespace string) func(next http.Handler) http.Handler {
+
+	apiConfig := Process(context.Background(), namespace)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyConfig, apiConfig)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
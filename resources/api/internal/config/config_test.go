@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestReadEnvConfigFresh(t *testing.T) {
+
+	t.Setenv("APPLICATION_TABLE_NAME", "first")
+	first := GetConfig(ReadEnvConfig(context.Background(), "APPLICATION"))
+
+	t.Setenv("APPLICATION_TABLE_NAME", "second")
+	second := GetConfig(ReadEnvConfig(context.Background(), "APPLICATION"))
+
+	if first == second || first.TableName != "first" || second.TableName != "second" {
+		t.Errorf("configs share state: %q and %q", first.TableName, second.TableName)
+	}
+}
+
+func TestMiddlewareReadsOnce(t *testing.T) {
+
+	t.Setenv("APPLICATION_TABLE_NAME", "startup")
+
+	var mu sync.Mutex
+	seen := map[*ApiConfig]bool{}
+
+	handler := Middleware("APPLICATION")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := GetConfig(r.Context())
+		mu.Lock()
+		seen[c] = true
+		mu.Unlock()
+	}))
+
+	t.Setenv("APPLICATION_TABLE_NAME", "changed")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 1 {
+		t.Fatalf("%d configs across requests, want the one read at startup", len(seen))
+	}
+	for c := range seen {
+		if c.TableName != "startup" {
+			t.Errorf("TableName is %q, want the startup value", c.TableName)
+		}
+	}
+}
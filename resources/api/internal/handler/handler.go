@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"api/internal/api"
+	"api/internal/config"
+
+	"api/pkg/health"
+	"api/pkg/log"
+	"api/pkg/log/chilogger"
+	"api/pkg/ratelimit"
+	"api/pkg/repository"
+	"api/pkg/secrets"
+	"api/pkg/version"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+// our router
+var chiLambda *chiadapter.ChiLambda
+
+// application storage, the table comes from the request scoped config
+var store repository.Repository
+
+// per caller token buckets, shared by every request this instance serves
+var limiter = ratelimit.New()
+
+// declared secrets, see secrets.ARN, cached across requests
+var secretCache *secrets.Cache
+
+func init() {
+
+	logger := log.Logger(context.TODO())
+
+	// stdout and stderr are sent to AWS CloudWatch Logs
+	if OnLambda() {
+		logger.Warn("lambda cold start")
+	}
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		LogLevel: log.AWSLevel(),
+		Logger:   &log.AWSLogger{},
+	}))
+
+	store = repository.NewDynamoDB(dynamodb.New(sess), tableName)
+
+	secretCache = secrets.New(secretsmanager.New(sess), secretTTL)
+
+	chiLambda = chiadapter.New(Router())
+
+	registerChecks(sess)
+}
+
+// tableName resolves the table from the request scoped config
+func tableName(ctx context.Context) string {
+	return config.GetConfig(ctx).TableName
+}
+
+// secretTTL resolves the secret refresh interval from the request scoped config
+func secretTTL(ctx context.Context) time.Duration {
+	return time.Duration(config.GetConfig(ctx).SecretTTL) * time.Second
+}
+
+// tenantConfig resolves the served tenants from the request scoped config
+func tenantConfig(ctx context.Context) api.TenantConfig {
+
+	c := config.GetConfig(ctx)
+
+	return api.TenantConfig{
+		Default: c.Tenant,
+		Allowed: c.Tenants,
+		Domain:  c.TenantDomain,
+		Claim:   c.TenantClaim,
+	}
+}
+
+// rateLimitConfig resolves the caller quotas from the request scoped config,
+// malformed tenant quotas are logged and fall back to the default
+func rateLimitConfig(ctx context.Context) api.RateLimitConfig {
+
+	c := config.GetConfig(ctx)
+
+	rl := api.RateLimitConfig{
+		Default: ratelimit.Quota{Rate: c.RateLimit, Burst: c.RateBurst},
+		Tenants: map[string]ratelimit.Quota{},
+	}
+
+	for tenant, limits := range c.TenantRateLimits {
+		q, err := ratelimit.ParseQuota(limits)
+		if err != nil {
+			log.Logger(ctx).Error("invalid tenant rate limit", zap.String("tenant", tenant), zap.Error(err))
+			continue
+		}
+		rl.Tenants[tenant] = q
+	}
+
+	return rl
+}
+
+// corsConfig resolves the browser access policy from the request scoped config
+func corsConfig(ctx context.Context) api.CorsConfig {
+
+	c := config.GetConfig(ctx)
+
+	return api.CorsConfig{
+		Origins:       c.Cors.OriginsFor(c.Environment),
+		Methods:       c.Cors.Methods,
+		Headers:       c.Cors.Headers,
+		ExposeHeaders: c.Cors.ExposeHeaders,
+		Credentials:   c.Cors.Credentials,
+		MaxAge:        time.Duration(c.Cors.MaxAge) * time.Second,
+	}
+}
+
+// registerChecks wires the readiness dependency checks, targets come from
+// the request scoped config so unconfigured dependencies are skipped
+func registerChecks(sess *session.Session) {
+
+	health.Register("ssm", health.DefaultTimeout, health.SSMCheck(ssm.New(sess), func(ctx context.Context) string {
+		return config.GetConfig(ctx).SSMPath
+	}))
+
+	health.Register("dynamodb", health.DefaultTimeout, health.DynamoDBCheck(dynamodb.New(sess), tableName))
+
+	health.Register("secrets", health.DefaultTimeout, func(ctx context.Context) error {
+
+		arns := secrets.Declared()
+		if len(arns) == 0 {
+			return health.ErrSkipped
+		}
+
+		for _, arn := range arns {
+			if _, err := secretCache.Get(ctx, arn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// OnLambda is true when running under the lambda runtime
+func OnLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// Router is shared between the lambda Handler, local mode and replay
+func Router() *chi.Mux {
+
+	r := chi.NewRouter()
+
+	// various middlewares
+	r.Use(middleware.RealIP)
+	r.Use(chilogger.Logger())
+	r.Use(api.Recoverer)
+	r.Use(api.TenantMiddleware(tenantConfig))
+	r.Use(api.RateLimitMiddleware(limiter, rateLimitConfig))
+	r.Use(render.SetContentType(render.ContentTypeJSON))
+
+	r.NotFound(api.NotFoundHandler)
+	r.MethodNotAllowed(api.MethodNotAllowedHandler)
+
+	r.Get("/health", health.LivenessHandler)
+	r.Get("/ready", health.ReadinessHandler)
+
+	r.Get("/version", version.Handler)
+
+	r.Get("/hello", api.HelloWorldHandler)
+
+	r.Mount("/notes", api.NotesRouter(store))
+
+	return r
+}
+
+// LocalHandler serves the Router outside of lambda, reading config once at
+// startup and applying CORS, which API Gateway handles when deployed
+func LocalHandler() http.Handler {
+	return config.Middleware("APPLICATION")(api.CorsMiddleware(corsConfig)(Router()))
+}
+
+// Handler is
+func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	logger := log.LoggerWithLambdaRqID(ctx)
+
+	xray.SetLogger(&log.XrayLogger{})
+
+	xray.Configure(xray.Config{
+		LogLevel:       "warn",
+		ServiceVersion: version.Version,
+	})
+
+	logger.Info("application handler")
+
+	logger.Debug("recieved event", zap.Reflect("req", req))
+
+	vctx := config.ReadEnvConfig(ctx, "APPLICATION")
+
+	return chiLambda.ProxyWithContext(vctx, req)
+}
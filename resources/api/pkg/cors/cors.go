@@ -0,0 +1,60 @@
+package cors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy is the browser access policy, the hosting stack applies it to the
+// HTTP API (CDK_CORS_*) and the api when serving locally (CORS_*)
+type Policy struct {
+	// allowed origins, * for any, empty disables CORS
+	Origins []string `envconfig:"ORIGINS"`
+	// per environment origins, override Origins
+	EnvironmentOrigins OriginsByEnvironment `envconfig:"ENVIRONMENT_ORIGINS"`
+	Methods            []string             `envconfig:"METHODS" default:"GET,PUT,DELETE"`
+	Headers            []string             `envconfig:"HEADERS" default:"Authorization,Content-Type"`
+	ExposeHeaders      []string             `envconfig:"EXPOSE_HEADERS" default:"Retry-After"`
+	Credentials        bool                 `envconfig:"CREDENTIALS" default:"false"`
+	// preflight cache in seconds
+	MaxAge int `envconfig:"MAX_AGE" default:"600"`
+}
+
+// OriginsFor returns the origins allowed in an environment
+func (p *Policy) OriginsFor(environment string) []string {
+
+	if origins, ok := p.EnvironmentOrigins[environment]; ok {
+		return origins
+	}
+
+	return p.Origins
+}
+
+// OriginsByEnvironment decodes environment=origin origin;... e.g.
+// "staging=https://staging.example.com http://localhost:3000;production=https://example.com",
+// origins hold colons so envconfig's own map format does not fit
+type OriginsByEnvironment map[string][]string
+
+// Decode implements envconfig.Decoder
+func (o *OriginsByEnvironment) Decode(value string) error {
+
+	m := OriginsByEnvironment{}
+
+	for _, entry := range strings.Split(value, ";") {
+
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return fmt.Errorf("expected environment=origins, got %q", entry)
+		}
+
+		m[strings.TrimSpace(kv[0])] = strings.Fields(kv[1])
+	}
+
+	*o = m
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package cors
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+func TestPolicy(t *testing.T) {
+
+	os.Setenv("TEST_CORS_ORIGINS", "https://example.com")
+	os.Setenv("TEST_CORS_ENVIRONMENT_ORIGINS", "staging=https://staging.example.com http://localhost:3000;production=")
+	defer os.Unsetenv("TEST_CORS_ORIGINS")
+	defer os.Unsetenv("TEST_CORS_ENVIRONMENT_ORIGINS")
+
+	var c struct {
+		Cors Policy `envconfig:"CORS"`
+	}
+	if err := envconfig.Process("TEST", &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Cors.MaxAge != 600 || !reflect.DeepEqual(c.Cors.Methods, []string{"GET", "PUT", "DELETE"}) {
+		t.Errorf("defaults not applied: %+v", c.Cors)
+	}
+
+	for environment, want := range map[string][]string{
+		"staging":     {"https://staging.example.com", "http://localhost:3000"},
+		"production":  {},
+		"development": {"https://example.com"},
+	} {
+		if got := c.Cors.OriginsFor(environment); !reflect.DeepEqual(got, want) {
+			t.Errorf("OriginsFor(%s) = %q, want %q", environment, got, want)
+		}
+	}
+
+	var o OriginsByEnvironment
+	if err := o.Decode("https://example.com"); err == nil {
+		t.Error("Decode accepted an entry without an environment")
+	}
+}
@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// SSMCheck confirms SSM is reachable and the parameter path is readable,
+// path is resolved per check so it can come from request scoped config
+func SSMCheck(client ssmiface.SSMAPI, path func(ctx context.Context) string) CheckFunc {
+	return func(ctx context.Context) error {
+
+		p := path(ctx)
+		if p == "" {
+			return ErrSkipped
+		}
+
+		_, err := client.GetParametersByPathWithContext(ctx, &ssm.GetParametersByPathInput{
+			Path:       aws.String(p),
+			MaxResults: aws.Int64(1),
+		})
+
+		return err
+	}
+}
+
+// DynamoDBCheck confirms the table exists and is ACTIVE
+func DynamoDBCheck(client dynamodbiface.DynamoDBAPI, table func(ctx context.Context) string) CheckFunc {
+	return func(ctx context.Context) error {
+
+		t := table(ctx)
+		if t == "" {
+			return ErrSkipped
+		}
+
+		out, err := client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(t),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if status := aws.StringValue(out.Table.TableStatus); status != dynamodb.TableStatusActive {
+			return &tableStatusError{table: t, status: status}
+		}
+
+		return nil
+	}
+}
+
+type tableStatusError struct {
+	table  string
+	status string
+}
+
+func (e *tableStatusError) Error() string {
+	return "table " + e.table + " is " + e.status
+}
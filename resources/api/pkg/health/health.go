@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"api/pkg/log"
+
+	"github.com/go-chi/render"
+	"go.uber.org/zap"
+)
+
+// ErrSkipped is returned by a check whose dependency isn't configured
+var ErrSkipped = errors.New("skipped")
+
+// default per check deadline
+const DefaultTimeout = 2 * time.Second
+
+// CheckFunc checks a single dependency, it must honour ctx cancellation
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// registered readiness checks
+var (
+	checksMu sync.RWMutex
+	checks   = map[string]check{}
+)
+
+// Register adds a dependency check to the readiness endpoint, a zero
+// timeout uses DefaultTimeout
+func Register(name string, timeout time.Duration, fn CheckFunc) {
+	checksMu.Lock()
+	defer checksMu.Unlock()
+
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	checks[name] = check{name: name, timeout: timeout, fn: fn}
+}
+
+// CheckResult is the outcome of a single check, the readiness endpoint is
+// unauthenticated so a failure's cause is only logged
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+func (u *healthResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// LivenessHandler reports the process is up, it never touches dependencies
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+
+	render.Status(r, http.StatusOK)
+
+	render.Render(w, r, &healthResponse{
+		Status: "ok",
+	})
+}
+
+// ReadinessHandler runs every registered check concurrently and reports 503
+// if any of them fail
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+
+	results := Run(r.Context())
+
+	status := "ok"
+	code := http.StatusOK
+
+	for _, result := range results {
+		if result.Status == "fail" {
+			status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	if code != http.StatusOK {
+		log.LoggerWithLambdaRqID(r.Context()).Warn("not ready", zap.Reflect("checks", results))
+	}
+
+	render.Status(r, code)
+
+	render.Render(w, r, &healthResponse{
+		Status: status,
+		Checks: results,
+	})
+}
+
+// Run executes the registered checks, ordered by name
+func Run(ctx context.Context) []CheckResult {
+
+	checksMu.RLock()
+	registered := make([]check, 0, len(checks))
+	for _, c := range checks {
+		registered = append(registered, c)
+	}
+	checksMu.RUnlock()
+
+	sort.Slice(registered, func(i, j int) bool {
+		return registered[i].name < registered[j].name
+	})
+
+	results := make([]CheckResult, len(registered))
+
+	var wg sync.WaitGroup
+
+	for i, c := range registered {
+		wg.Add(1)
+
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func runCheck(ctx context.Context, c check) CheckResult {
+
+	cctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				errc <- errors.New("check panicked")
+			}
+		}()
+		errc <- c.fn(cctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-errc:
+	case <-cctx.Done():
+		err = cctx.Err()
+	}
+
+	result := CheckResult{
+		Name:      c.name,
+		Status:    "ok",
+		LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+
+	switch {
+	case errors.Is(err, ErrSkipped):
+		result.Status = "skipped"
+	case err != nil:
+		result.Status = "fail"
+		log.LoggerWithLambdaRqID(ctx).Warn("check failed", zap.String("check", c.name), zap.Error(err))
+	}
+
+	return result
+}
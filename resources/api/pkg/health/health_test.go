@@ -0,0 +1,125 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api/pkg/log"
+)
+
+// registered replaces the registered checks for the duration of a test
+func registered(t *testing.T) {
+	t.Helper()
+
+	checksMu.Lock()
+	saved := checks
+	checks = map[string]check{}
+	checksMu.Unlock()
+
+	t.Cleanup(func() {
+		checksMu.Lock()
+		checks = saved
+		checksMu.Unlock()
+	})
+}
+
+func TestRun(t *testing.T) {
+
+	registered(t)
+	log.SetOutput(ioutil.Discard)
+
+	failed := errors.New("AccessDeniedException: User arn:aws:sts::123456789012:assumed-role/api is not authorized")
+
+	Register("ssm", 0, func(ctx context.Context) error { return nil })
+	Register("dynamodb", 0, func(ctx context.Context) error { return failed })
+	Register("secrets", 0, func(ctx context.Context) error { return ErrSkipped })
+	Register("cache", 0, func(ctx context.Context) error { panic("boom") })
+	Register("slow", 10*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	Register("stuck", 10*time.Millisecond, func(ctx context.Context) error {
+		// ignores ctx, the check still ends at its deadline
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	results := Run(context.Background())
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Run took %s, past the checks' timeouts", elapsed)
+	}
+
+	want := []struct{ name, status string }{
+		{"cache", "fail"},
+		{"dynamodb", "fail"},
+		{"secrets", "skipped"},
+		{"slow", "fail"},
+		{"ssm", "ok"},
+		{"stuck", "fail"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		if results[i].Name != w.name || results[i].Status != w.status {
+			t.Errorf("result %d is %s %s, want %s %s", i, results[i].Name, results[i].Status, w.name, w.status)
+		}
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+
+	registered(t)
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(ioutil.Discard) })
+
+	Register("dynamodb", 0, func(ctx context.Context) error {
+		return errors.New("AccessDeniedException: not authorized on table AcmeTestNotes")
+	})
+
+	w := httptest.NewRecorder()
+	ReadinessHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	// the cause is logged, not returned on the unauthenticated route
+	body := w.Body.String()
+	if !strings.Contains(body, `"name":"dynamodb","status":"fail"`) {
+		t.Errorf("body is %s", body)
+	}
+	if strings.Contains(body, "AccessDenied") || strings.Contains(body, "AcmeTestNotes") {
+		t.Errorf("body leaks the error: %s", body)
+	}
+	if !strings.Contains(logged.String(), "AccessDeniedException") {
+		t.Errorf("error not logged: %s", logged.String())
+	}
+}
+
+func TestReadinessHandlerOK(t *testing.T) {
+
+	registered(t)
+
+	Register("ssm", 0, func(ctx context.Context) error { return nil })
+	Register("secrets", 0, func(ctx context.Context) error { return ErrSkipped })
+
+	w := httptest.NewRecorder()
+	ReadinessHandler(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	// skipped checks do not fail readiness
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ok"`) {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+}
@@ -1,18 +1,23 @@
 package chilogger
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"api/pkg/log"
+	"api/pkg/metrics"
 
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
 	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
 // Logger is a middleware that logs the start and end of each request, along
 // with some useful data about what was requested, what the response status was,
-// and how long it took to return.
+// and how long it took to return. Latency and request counts are also emitted
+// as EMF metrics, along with any business metrics recorded by the handler.
 func Logger() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
@@ -21,22 +26,75 @@ func Logger() func(next http.Handler) http.Handler {
 
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
+			// pin the request ID for the rest of the chain, outside of lambda
+			// honour a caller supplied ID or generate one
+			rqID := r.Header.Get(middleware.RequestIDHeader)
+			if rqID == "" {
+				rqID = log.RequestID(r.Context())
+			}
+			r = r.WithContext(log.WithRqID(r.Context(), rqID))
+
 			l := log.LoggerWithLambdaRqID(r.Context())
 
+			// the API Gateway request ID, also written to the stage access log
+			if apigw, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok && apigw.RequestID != "" {
+				l = l.With(zap.String("requestId", apigw.RequestID))
+			}
+
+			m := metrics.New()
+
 			defer func() {
 
+				took := time.Since(start)
+
 				l.Info("request complete",
 					zap.String("proto", r.Proto),
 					zap.String("path", r.URL.Path),
 					zap.String("remote", r.RemoteAddr),
-					zap.Duration("took", time.Since(start)),
+					zap.Duration("took", took),
 					zap.Int("status", ww.Status()),
 					zap.Int("size", ww.BytesWritten()),
+					// resolved further down the chain, see api.TenantMiddleware
+					zap.String("tenant", m.Dimension("Tenant")),
 				)
+
+				m.PutDimension("Route", routePattern(r))
+				m.PutDimension("Method", r.Method)
+				m.PutDimension("StatusClass", statusClass(ww.Status()))
+
+				m.PutMetric("Latency", float64(took)/float64(time.Millisecond), metrics.UnitMilliseconds)
+				m.PutMetric("Requests", 1, metrics.UnitCount)
+				m.PutMetric("ResponseSize", float64(ww.BytesWritten()), metrics.UnitBytes)
+
+				m.Flush()
 			}()
 
-			next.ServeHTTP(ww, r)
+			next.ServeHTTP(ww, r.WithContext(metrics.WithMetrics(r.Context(), m)))
 		}
 		return http.HandlerFunc(fn)
 	}
 }
+
+// routePattern returns the matched chi route, so high cardinality paths
+// collapse to their template e.g. /items/{id}
+func routePattern(r *http.Request) string {
+
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return "unmatched"
+}
+
+// statusClass buckets a status code e.g. 404 -> 4xx
+func statusClass(status int) string {
+
+	// handler never wrote a header
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return fmt.Sprintf("%dxx", status/100)
+}
@@ -2,8 +2,12 @@ package log
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 	"os"
 	"strings"
+	"sync"
 
 	"api/pkg/version"
 
@@ -17,6 +21,7 @@ type correlationIDType int
 const (
 	requestIDKey correlationIDType = iota
 	sessionIDKey
+	tenantKey
 )
 
 // Default logger of the system.
@@ -25,6 +30,27 @@ var logger *zap.Logger
 // code environment
 var environment string
 
+// current log level, adjustable at runtime
+var atomicLevel zap.AtomicLevel
+
+// where log lines are written, swappable so tools can keep stdout clean
+var output = &syncWriter{w: os.Stdout}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *syncWriter) Sync() error {
+	return nil
+}
+
 var logLevelSeverity = map[string]zapcore.Level{
 	"DEBUG":     zapcore.DebugLevel,
 	"INFO":      zapcore.InfoLevel,
@@ -61,7 +87,9 @@ func init() {
 	//}
 	encoder := zapcore.NewJSONEncoder(config)
 
-	core := zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), zap.NewAtomicLevelAt(logLevelSeverity[logLevel]))
+	atomicLevel = zap.NewAtomicLevelAt(logLevelSeverity[logLevel])
+
+	core := zapcore.NewCore(encoder, output, atomicLevel)
 	defaultLogger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 
 	defer defaultLogger.Sync()
@@ -69,21 +97,86 @@ func init() {
 	logger = defaultLogger.With(zap.String("v", buildVersion), zap.String("bh", buildHash), zap.String("bd", buildDate), zap.String("env", environment))
 }
 
-// LoggerWithLambdaRqID returns a logger with lambda context
-func LoggerWithLambdaRqID(ctx context.Context) *zap.Logger {
+// SetOutput redirects log output, stdout is shipped to CloudWatch Logs so
+// this is only useful for local tooling
+func SetOutput(w io.Writer) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	output.w = w
+}
 
-	lc, _ := lambdacontext.FromContext(ctx)
+// SetLevel changes the log level e.g. once a local config file has been read
+func SetLevel(level string) {
 
-	rqCtx := WithRqID(ctx, lc.AwsRequestID)
+	if l, ok := logLevelSeverity[strings.ToUpper(level)]; ok {
+		atomicLevel.SetLevel(l)
+	}
+}
+
+// LoggerWithLambdaRqID returns a logger with lambda context, outside of
+// lambda it falls back to the request ID already held by the context
+func LoggerWithLambdaRqID(ctx context.Context) *zap.Logger {
+
+	rqCtx := WithRqID(ctx, RequestID(ctx))
 
 	return Logger(rqCtx)
 }
 
+// RequestID returns the lambda request ID, else the ID held by the context,
+// else a newly generated ID
+func RequestID(ctx context.Context) string {
+
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
+	}
+
+	if ctxRqID, ok := ctx.Value(requestIDKey).(string); ok && ctxRqID != "" {
+		return ctxRqID
+	}
+
+	return NewRequestID()
+}
+
+// NewRequestID generates a random, UUID formatted, request ID
+func NewRequestID() string {
+
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+
+	// version 4, variant 10
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b)
+
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
 // WithRqID returns a context which knows its request ID
 func WithRqID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKey, requestID)
 }
 
+// WithTenant returns a context which knows the tenant being served
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant returns the tenant held by the context, empty if unresolved
+func Tenant(ctx context.Context) string {
+
+	if ctx == nil {
+		return ""
+	}
+
+	tenant, _ := ctx.Value(tenantKey).(string)
+
+	return tenant
+}
+
 // Logger returns a zap logger with as much context as possible
 func Logger(ctx context.Context) *zap.Logger {
 
@@ -97,5 +190,9 @@ func Logger(ctx context.Context) *zap.Logger {
 		newLogger = newLogger.With(zap.String("rqID", ctxRqID))
 	}
 
+	if tenant := Tenant(ctx); tenant != "" {
+		newLogger = newLogger.With(zap.String("tenant", tenant))
+	}
+
 	return newLogger
 }
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"context"
+)
+
+// The key type is unexported to prevent collisions with context keys defined in
+// other packages.
+type contextKey string
+
+func (c contextKey) String() string {
+	return "context key " + string(c)
+}
+
+var (
+	contextKeyMetrics = contextKey("metrics")
+)
+
+// WithMetrics returns a context carrying a request scoped metrics logger
+func WithMetrics(ctx context.Context, m *Logger) context.Context {
+	return context.WithValue(ctx, contextKeyMetrics, m)
+}
+
+// FromContext returns the request scoped metrics logger, or nil if there is none
+func FromContext(ctx context.Context) *Logger {
+
+	if ctx == nil {
+		return nil
+	}
+
+	m, _ := ctx.Value(contextKeyMetrics).(*Logger)
+
+	return m
+}
+
+// Put records a custom business metric against the current request, it is a
+// no-op outside of a request instrumented by the chilogger middleware
+func Put(ctx context.Context, name string, value float64, unit Unit) {
+
+	if m := FromContext(ctx); m != nil {
+		m.PutMetric(name, value, unit)
+	}
+}
+
+// Count increments a custom business counter against the current request
+func Count(ctx context.Context, name string) {
+	Put(ctx, name, 1, UnitCount)
+}
+
+// Dimension adds (or replaces) a dimension on the current request's metric document
+func Dimension(ctx context.Context, name, value string) {
+
+	if m := FromContext(ctx); m != nil {
+		m.PutDimension(name, value)
+	}
+}
+
+// Property attaches a searchable field to the current request's metric document
+func Property(ctx context.Context, name string, value interface{}) {
+
+	if m := FromContext(ctx); m != nil {
+		m.PutProperty(name, value)
+	}
+}
@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"api/pkg/log"
+
+	"go.uber.org/zap"
+)
+
+// Unit is a CloudWatch metric unit
+type Unit string
+
+// Supported CloudWatch units
+const (
+	UnitNone         Unit = "None"
+	UnitCount        Unit = "Count"
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitSeconds      Unit = "Seconds"
+	UnitBytes        Unit = "Bytes"
+	UnitPercent      Unit = "Percent"
+)
+
+// default metric namespace
+var namespace string
+
+// default dimensions applied to every metric document
+var tenant string
+var environment string
+
+// where EMF documents are written, stdout is shipped to CloudWatch Logs
+var out io.Writer = os.Stdout
+var outMu sync.Mutex
+
+func init() {
+
+	namespace = os.Getenv("METRICS_NAMESPACE")
+
+	if namespace == "" {
+		namespace = "api"
+	}
+
+	tenant = os.Getenv("TENANT")
+
+	if tenant == "" {
+		tenant = "unknown"
+	}
+
+	environment = os.Getenv("ENVIRONMENT")
+
+	if environment == "" {
+		environment = "development"
+	}
+}
+
+// SetOutput redirects EMF documents, only useful for local tooling
+func SetOutput(w io.Writer) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	out = w
+}
+
+type metricValue struct {
+	Unit   Unit
+	Values []float64
+}
+
+// Logger collects metrics, dimensions and properties and emits them as a
+// single CloudWatch Embedded Metric Format document on Flush
+type Logger struct {
+	mu sync.Mutex
+
+	namespace  string
+	dimensions []string
+	values     map[string]string
+	metrics    map[string]*metricValue
+	order      []string
+	properties map[string]interface{}
+}
+
+// New returns a metrics logger carrying the default Tenant and Environment dimensions
+func New() *Logger {
+
+	m := &Logger{
+		namespace:  namespace,
+		values:     map[string]string{},
+		metrics:    map[string]*metricValue{},
+		properties: map[string]interface{}{},
+	}
+
+	m.PutDimension("Tenant", tenant)
+	m.PutDimension("Environment", environment)
+
+	return m
+}
+
+// PutDimension adds (or replaces) a dimension on every metric in the document
+func (m *Logger) PutDimension(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.values[name]; !ok {
+		m.dimensions = append(m.dimensions, name)
+	}
+
+	m.values[name] = value
+}
+
+// Dimension returns the value of a dimension, empty if it is not set
+func (m *Logger) Dimension(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.values[name]
+}
+
+// PutMetric records a value against a metric, repeated values are aggregated
+// into a single array within the document
+func (m *Logger) PutMetric(name string, value float64, unit Unit) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mv, ok := m.metrics[name]
+
+	if !ok {
+		mv = &metricValue{Unit: unit}
+		m.metrics[name] = mv
+		m.order = append(m.order, name)
+	}
+
+	mv.Values = append(mv.Values, value)
+}
+
+// PutProperty adds a searchable, non metric, field to the document
+func (m *Logger) PutProperty(name string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.properties[name] = value
+}
+
+// Flush writes the document to stdout and resets the recorded metrics
+func (m *Logger) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.metrics) == 0 {
+		return
+	}
+
+	doc, err := json.Marshal(m.document(time.Now()))
+
+	if err != nil {
+		log.Logger(context.TODO()).Error("unable to marshal metrics", zap.Error(err))
+		return
+	}
+
+	outMu.Lock()
+	defer outMu.Unlock()
+
+	out.Write(append(doc, '\n'))
+
+	m.metrics = map[string]*metricValue{}
+	m.order = nil
+}
+
+type metricDefinition struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type metricDirective struct {
+	Namespace  string             `json:"Namespace"`
+	Dimensions [][]string         `json:"Dimensions"`
+	Metrics    []metricDefinition `json:"Metrics"`
+}
+
+type metadata struct {
+	Timestamp         int64             `json:"Timestamp"`
+	CloudWatchMetrics []metricDirective `json:"CloudWatchMetrics"`
+}
+
+// document builds the EMF structure, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+func (m *Logger) document(t time.Time) map[string]interface{} {
+
+	doc := map[string]interface{}{}
+
+	for k, v := range m.properties {
+		doc[k] = v
+	}
+
+	for k, v := range m.values {
+		doc[k] = v
+	}
+
+	directive := metricDirective{
+		Namespace:  m.namespace,
+		Dimensions: [][]string{append([]string{}, m.dimensions...)},
+	}
+
+	for _, name := range m.order {
+		mv := m.metrics[name]
+
+		directive.Metrics = append(directive.Metrics, metricDefinition{Name: name, Unit: mv.Unit})
+
+		if len(mv.Values) == 1 {
+			doc[name] = mv.Values[0]
+		} else {
+			doc[name] = mv.Values
+		}
+	}
+
+	doc["_aws"] = metadata{
+		Timestamp:         t.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []metricDirective{directive},
+	}
+
+	return doc
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// capture redirects the documents for the duration of a test
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	t.Cleanup(func() { SetOutput(os.Stdout) })
+
+	return &buf
+}
+
+// documents decodes the flushed documents, one per line
+func documents(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var docs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		doc := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs
+}
+
+func asJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDocument(t *testing.T) {
+
+	buf := capture(t)
+
+	m := New()
+	m.PutDimension("Route", "/notes")
+	m.PutMetric("Latency", 12, UnitMilliseconds)
+	m.PutMetric("Notes", 1, UnitCount)
+	m.PutMetric("Notes", 2, UnitCount)
+	m.PutProperty("requestId", "abc")
+	m.Flush()
+
+	docs := documents(t, buf)
+	if len(docs) != 1 {
+		t.Fatalf("%d documents, want 1", len(docs))
+	}
+	doc := docs[0]
+
+	aws, ok := doc["_aws"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("_aws is %v", doc["_aws"])
+	}
+	if _, ok := aws["Timestamp"].(float64); !ok {
+		t.Errorf("Timestamp is %v", aws["Timestamp"])
+	}
+
+	want := `[{"Dimensions":[["Tenant","Environment","Route"]],"Metrics":[{"Name":"Latency","Unit":"Milliseconds"},{"Name":"Notes","Unit":"Count"}],"Namespace":` + asJSON(t, namespace) + `}]`
+	if got := asJSON(t, aws["CloudWatchMetrics"]); got != want {
+		t.Errorf("CloudWatchMetrics is %s, want %s", got, want)
+	}
+
+	// a value recorded once stays a number, repeated values an array
+	for name, want := range map[string]string{
+		"Latency":     `12`,
+		"Notes":       `[1,2]`,
+		"Tenant":      asJSON(t, tenant),
+		"Environment": asJSON(t, environment),
+		"Route":       `"/notes"`,
+		"requestId":   `"abc"`,
+	} {
+		if got := asJSON(t, doc[name]); got != want {
+			t.Errorf("%s is %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestPutDimensionReplaces(t *testing.T) {
+
+	m := New()
+	m.PutDimension("Tenant", "acme")
+
+	if got := m.Dimension("Tenant"); got != "acme" {
+		t.Errorf("Tenant is %q, want acme", got)
+	}
+	if got := asJSON(t, m.dimensions); got != `["Tenant","Environment"]` {
+		t.Errorf("dimensions are %s", got)
+	}
+}
+
+func TestFlushResets(t *testing.T) {
+
+	buf := capture(t)
+
+	m := New()
+	m.PutMetric("Notes", 1, UnitCount)
+	m.Flush()
+
+	// nothing recorded since, nothing written
+	m.Flush()
+	if docs := documents(t, buf); len(docs) != 1 {
+		t.Fatalf("%d documents after flushing twice, want 1", len(docs))
+	}
+
+	// the dimensions stay, the metrics start over
+	m.PutMetric("Errors", 1, UnitCount)
+	m.Flush()
+
+	docs := documents(t, buf)
+	if len(docs) != 2 {
+		t.Fatalf("%d documents, want 2", len(docs))
+	}
+	if _, ok := docs[1]["Notes"]; ok {
+		t.Error("Notes flushed twice")
+	}
+	if got := asJSON(t, docs[1]["_aws"].(map[string]interface{})["CloudWatchMetrics"]); got != `[{"Dimensions":[["Tenant","Environment"]],"Metrics":[{"Name":"Errors","Unit":"Count"}],"Namespace":`+asJSON(t, namespace)+`}]` {
+		t.Errorf("CloudWatchMetrics is %s", got)
+	}
+}
+
+func TestPutWithoutLogger(t *testing.T) {
+
+	buf := capture(t)
+
+	// outside of a request there is no logger, and nothing to write to
+	ctx := context.Background()
+	Put(ctx, "Notes", 1, UnitCount)
+	Count(ctx, "Notes")
+	Dimension(ctx, "Route", "/notes")
+	Property(ctx, "requestId", "abc")
+
+	if FromContext(ctx) != nil {
+		t.Error("FromContext returned a logger")
+	}
+
+	m := New()
+	ctx = WithMetrics(ctx, m)
+	Count(ctx, "Notes")
+	m.Flush()
+
+	if docs := documents(t, buf); len(docs) != 1 || asJSON(t, docs[0]["Notes"]) != `1` {
+		t.Errorf("documents are %v", docs)
+	}
+}
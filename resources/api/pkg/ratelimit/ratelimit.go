@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quota is a token bucket, Rate tokens per second up to Burst tokens
+type Quota struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited reports whether the quota disables limiting
+func (q Quota) Unlimited() bool {
+	return q.Rate <= 0
+}
+
+// NewQuota checks a rate and burst, the burst must be a whole number
+func NewQuota(rate, burst float64) (Quota, error) {
+
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return Quota{}, fmt.Errorf("invalid rate %v", rate)
+	}
+
+	if burst < 0 || burst != math.Trunc(burst) || burst > math.MaxInt32 {
+		return Quota{}, fmt.Errorf("invalid burst %v", burst)
+	}
+
+	return Quota{Rate: rate, Burst: int(burst)}, nil
+}
+
+// ParseQuota reads rate/burst e.g. 10/20
+func ParseQuota(s string) (Quota, error) {
+
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Quota{}, fmt.Errorf("expected rate/burst, got %q", s)
+	}
+
+	rate, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return Quota{}, fmt.Errorf("invalid rate %q", parts[0])
+	}
+
+	burst, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return Quota{}, fmt.Errorf("invalid burst %q", parts[1])
+	}
+
+	return NewQuota(rate, burst)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key. Buckets live in process memory, so
+// under Lambda each instance enforces its own share of the quota.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	// Now is the clock used to refill buckets
+	Now func() time.Time
+	// MaxKeys bounds memory, idle full buckets are dropped beyond it
+	MaxKeys int
+}
+
+// New returns an empty Limiter
+func New() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		Now:     time.Now,
+		MaxKeys: 10000,
+	}
+}
+
+// Allow takes a token from the key's bucket, when none is left it returns
+// false and how long until one is available
+func (l *Limiter) Allow(key string, q Quota) (bool, time.Duration) {
+
+	if q.Unlimited() {
+		return true, 0
+	}
+
+	burst := float64(q.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.MaxKeys {
+			l.evict(now)
+		}
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// refill since the last request
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*q.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / q.Rate * float64(time.Second))
+
+	return false, wait
+}
+
+// evict drops idle buckets, any bucket idle for a minute has refilled at
+// all but the slowest rates. If every bucket is busy an arbitrary one
+// is reset, erring towards letting a caller through. Callers hold mu.
+func (l *Limiter) evict(now time.Time) {
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+
+	for key := range l.buckets {
+		if len(l.buckets) < l.MaxKeys {
+			break
+		}
+		delete(l.buckets, key)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+
+	l := New()
+	l.Now = func() time.Time { return now }
+
+	q := Quota{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", q); !ok {
+			t.Fatalf("request %d inside the burst was limited", i)
+		}
+	}
+
+	ok, wait := l.Allow("a", q)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("over burst = %v %s, want limited for 500ms", ok, wait)
+	}
+
+	if ok, _ := l.Allow("b", q); !ok {
+		t.Fatal("keys share a bucket")
+	}
+
+	now = now.Add(wait)
+
+	if ok, _ := l.Allow("a", q); !ok {
+		t.Fatal("bucket did not refill")
+	}
+
+	if ok, _ := l.Allow("a", Quota{}); !ok {
+		t.Fatal("zero quota limited")
+	}
+}
+
+func TestLimiterMaxKeys(t *testing.T) {
+
+	l := New()
+	l.MaxKeys = 2
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		l.Allow(key, Quota{Rate: 1, Burst: 1})
+	}
+
+	if len(l.buckets) > 2 {
+		t.Fatalf("%d buckets, want at most 2", len(l.buckets))
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+
+	if q, err := ParseQuota("2.5/10"); err != nil || q != (Quota{Rate: 2.5, Burst: 10}) {
+		t.Fatalf("ParseQuota = %+v %v", q, err)
+	}
+
+	for _, bad := range []string{"", "10", "x/1", "1/x", "-1/1", "1/2/3", "1/2.5", "1/-1", "NaN/1"} {
+		if _, err := ParseQuota(bad); err == nil {
+			t.Errorf("ParseQuota(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+var _ Repository = (*DynamoDB)(nil)
+
+// DynamoDB is the table backed Repository
+type DynamoDB struct {
+	client dynamodbiface.DynamoDBAPI
+	table  func(ctx context.Context) string
+}
+
+// NewDynamoDB returns a Repository, table is resolved per call so it can
+// come from request scoped config
+func NewDynamoDB(client dynamodbiface.DynamoDBAPI, table func(ctx context.Context) string) *DynamoDB {
+	return &DynamoDB{
+		client: client,
+		table:  table,
+	}
+}
+
+func (d *DynamoDB) tableName(ctx context.Context) (*string, error) {
+	t := d.table(ctx)
+	if t == "" {
+		return nil, ErrNoTable
+	}
+	return aws.String(t), nil
+}
+
+// Get reads the item at key into out
+func (d *DynamoDB) Get(ctx context.Context, key Key, out Record) error {
+
+	table, err := d.tableName(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := d.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      table,
+		Key:            keyAttributes(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(res.Item) == 0 || expired(res.Item, time.Now()) {
+		return ErrNotFound
+	}
+
+	return dynamodbattribute.UnmarshalMap(res.Item, out)
+}
+
+// Put writes rec, incrementing its Version on success
+func (d *DynamoDB) Put(ctx context.Context, rec Record, cond Condition) error {
+
+	table, err := d.tableName(ctx)
+	if err != nil {
+		return err
+	}
+
+	it := rec.item()
+
+	expected := it.Version
+	it.Version++
+
+	av, err := dynamodbattribute.MarshalMap(rec)
+	if err != nil {
+		it.Version = expected
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: table,
+		Item:      av,
+	}
+
+	if c, ok := condition(cond, expected, time.Now()); ok {
+		expr, err := expression.NewBuilder().WithCondition(c).Build()
+		if err != nil {
+			it.Version = expected
+			return err
+		}
+
+		input.ConditionExpression = expr.Condition()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+	}
+
+	if _, err := d.client.PutItemWithContext(ctx, input); err != nil {
+		it.Version = expected
+		return translate(err)
+	}
+
+	return nil
+}
+
+// Delete removes rec, IfVersion uses the rec Version
+func (d *DynamoDB) Delete(ctx context.Context, rec Record, cond Condition) error {
+
+	table, err := d.tableName(ctx)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: table,
+		Key:       keyAttributes(rec.Key()),
+	}
+
+	if c, ok := condition(cond, rec.item().Version, time.Now()); ok {
+		expr, err := expression.NewBuilder().WithCondition(c).Build()
+		if err != nil {
+			return err
+		}
+
+		input.ConditionExpression = expr.Condition()
+		input.ExpressionAttributeNames = expr.Names()
+		input.ExpressionAttributeValues = expr.Values()
+	}
+
+	_, err = d.client.DeleteItemWithContext(ctx, input)
+
+	return translate(err)
+}
+
+// Query reads a page of items into out, a pointer to a slice. Expired items
+// are filtered after Limit is applied so a page may be short.
+func (d *DynamoDB) Query(ctx context.Context, q Query, out interface{}) (Page, error) {
+
+	table, err := d.tableName(ctx)
+	if err != nil {
+		return Page{}, err
+	}
+
+	start, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	pkName, skName := indexKeys(q.Index)
+
+	keyCond := expression.Key(pkName).Equal(expression.Value(q.PK))
+	if q.SKPrefix != "" {
+		keyCond = keyCond.And(expression.Key(skName).BeginsWith(q.SKPrefix))
+	}
+
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithFilter(live(time.Now())).Build()
+	if err != nil {
+		return Page{}, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 table,
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ExclusiveStartKey:         start,
+		ScanIndexForward:          aws.Bool(!q.Descending),
+	}
+
+	if q.Index != "" {
+		input.IndexName = aws.String(q.Index)
+	}
+
+	if q.Limit > 0 {
+		input.Limit = aws.Int64(q.Limit)
+	}
+
+	res, err := d.client.QueryWithContext(ctx, input)
+	if err != nil {
+		return Page{}, err
+	}
+
+	if err := dynamodbattribute.UnmarshalListOfMaps(res.Items, out); err != nil {
+		return Page{}, err
+	}
+
+	next, err := encodeCursor(res.LastEvaluatedKey)
+
+	return Page{Next: next}, err
+}
+
+func keyAttributes(key Key) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(key.PK)},
+		"SK": {S: aws.String(key.SK)},
+	}
+}
+
+// condition builds the write condition, false when there is none. Expired
+// items which haven't been deleted yet are treated as absent.
+func condition(cond Condition, version int64, now time.Time) (expression.ConditionBuilder, bool) {
+
+	ttl := expression.Name(TTLAttribute)
+
+	switch cond {
+	case IfNotExists:
+		return expression.Name("PK").AttributeNotExists().Or(
+			ttl.AttributeExists().And(ttl.LessThanEqual(expression.Value(now.Unix()))),
+		), true
+	case IfExists:
+		return expression.Name("PK").AttributeExists().And(live(now)), true
+	case IfVersion:
+		return expression.Name("Version").Equal(expression.Value(version)).And(live(now)), true
+	}
+
+	return expression.ConditionBuilder{}, false
+}
+
+// live matches items without a TTL or whose TTL hasn't passed
+func live(now time.Time) expression.ConditionBuilder {
+	ttl := expression.Name(TTLAttribute)
+	return ttl.AttributeNotExists().Or(ttl.GreaterThan(expression.Value(now.Unix())))
+}
+
+// expired reports whether the TTL has passed, DynamoDB deletes expired items
+// lazily so they can still be read for a while
+func expired(av map[string]*dynamodb.AttributeValue, now time.Time) bool {
+
+	ttl, ok := av[TTLAttribute]
+	if !ok || ttl.N == nil {
+		return false
+	}
+
+	secs, err := strconv.ParseInt(*ttl.N, 10, 64)
+	if err != nil || secs == 0 {
+		return false
+	}
+
+	return secs <= now.Unix()
+}
+
+func translate(err error) error {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrConditionFailed
+	}
+	return err
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// stubDynamoDB records the requests and answers with the canned outputs
+type stubDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	get    *dynamodb.GetItemInput
+	put    *dynamodb.PutItemInput
+	delete *dynamodb.DeleteItemInput
+	query  *dynamodb.QueryInput
+
+	item map[string]*dynamodb.AttributeValue
+	page dynamodb.QueryOutput
+	err  error
+}
+
+func (s *stubDynamoDB) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	s.get = in
+	return &dynamodb.GetItemOutput{Item: s.item}, s.err
+}
+
+func (s *stubDynamoDB) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	s.put = in
+	return &dynamodb.PutItemOutput{}, s.err
+}
+
+func (s *stubDynamoDB) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	s.delete = in
+	return &dynamodb.DeleteItemOutput{}, s.err
+}
+
+func (s *stubDynamoDB) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	s.query = in
+	return &s.page, s.err
+}
+
+func newStubbed() (*DynamoDB, *stubDynamoDB) {
+	stub := &stubDynamoDB{}
+	return NewDynamoDB(stub, func(context.Context) string { return "table" }), stub
+}
+
+// resolve substitutes the placeholders, e.g. #0 = :0 becomes Version = 1
+func resolve(expr *string, names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+
+	pairs := []string{}
+	for placeholder, name := range names {
+		pairs = append(pairs, placeholder, aws.StringValue(name))
+	}
+	for placeholder, value := range values {
+		switch {
+		case value.S != nil:
+			pairs = append(pairs, placeholder, `"`+*value.S+`"`)
+		case value.N != nil:
+			pairs = append(pairs, placeholder, *value.N)
+		}
+	}
+
+	// longest first, #10 before #1
+	sorted := [][2]string{}
+	for i := 0; i < len(pairs); i += 2 {
+		sorted = append(sorted, [2]string{pairs[i], pairs[i+1]})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i][0]) > len(sorted[j][0]) })
+
+	resolved := aws.StringValue(expr)
+	for _, pair := range sorted {
+		resolved = strings.ReplaceAll(resolved, pair[0], pair[1])
+	}
+
+	return resolved
+}
+
+func TestDynamoDBKeyAttributes(t *testing.T) {
+
+	repo, stub := newStubbed()
+
+	if err := repo.Get(context.Background(), Key{PK: "WIDGET#a", SK: "WIDGET"}, &widget{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get without an item = %v, want ErrNotFound", err)
+	}
+
+	key := stub.get.Key
+	if len(key) != 2 || aws.StringValue(key["PK"].S) != "WIDGET#a" || aws.StringValue(key["SK"].S) != "WIDGET" {
+		t.Errorf("key attributes %v", key)
+	}
+	if !aws.BoolValue(stub.get.ConsistentRead) || aws.StringValue(stub.get.TableName) != "table" {
+		t.Errorf("get input %v", stub.get)
+	}
+
+	// expired but not yet deleted by DynamoDB
+	stub.item = map[string]*dynamodb.AttributeValue{
+		"PK":         {S: aws.String("WIDGET#a")},
+		"SK":         {S: aws.String("WIDGET")},
+		TTLAttribute: {N: aws.String("1")},
+	}
+	if err := repo.Get(context.Background(), Key{PK: "WIDGET#a", SK: "WIDGET"}, &widget{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get expired = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDynamoDBConditions(t *testing.T) {
+
+	now := time.Now().Unix()
+
+	tests := []struct {
+		cond Condition
+		want string
+	}{
+		{Unconditional, ""},
+		{IfNotExists, `(attribute_not_exists (PK)) OR ((attribute_exists (ttl)) AND (ttl <= NOW))`},
+		{IfExists, `(attribute_exists (PK)) AND ((attribute_not_exists (ttl)) OR (ttl > NOW))`},
+		{IfVersion, `(Version = 3) AND ((attribute_not_exists (ttl)) OR (ttl > NOW))`},
+	}
+
+	for _, tt := range tests {
+
+		repo, stub := newStubbed()
+
+		w := newWidget("a")
+		w.Version = 3
+
+		if err := repo.Put(context.Background(), w, tt.cond); err != nil {
+			t.Fatal(err)
+		}
+		if w.Version != 4 || aws.StringValue(stub.put.Item["Version"].N) != "4" {
+			t.Errorf("condition %d: wrote version %v, record has %d, want 4", tt.cond, stub.put.Item["Version"], w.Version)
+		}
+
+		// the clock may tick between the write and the check
+		got := resolve(stub.put.ConditionExpression, stub.put.ExpressionAttributeNames, stub.put.ExpressionAttributeValues)
+		for _, n := range []int64{now, now + 1} {
+			got = strings.ReplaceAll(got, strconv.FormatInt(n, 10), "NOW")
+		}
+		if got != tt.want {
+			t.Errorf("condition %d: put %q, want %q", tt.cond, got, tt.want)
+		}
+
+		if err := repo.Delete(context.Background(), w, tt.cond); err != nil {
+			t.Fatal(err)
+		}
+		if key := stub.delete.Key; aws.StringValue(key["PK"].S) != "WIDGET#a" || len(key) != 2 {
+			t.Errorf("condition %d: delete key %v", tt.cond, key)
+		}
+		if tt.cond == IfVersion && !strings.Contains(resolve(stub.delete.ConditionExpression, stub.delete.ExpressionAttributeNames, stub.delete.ExpressionAttributeValues), "Version = 4") {
+			t.Errorf("delete does not check the record version: %s", aws.StringValue(stub.delete.ConditionExpression))
+		}
+	}
+}
+
+func TestDynamoDBConditionFailed(t *testing.T) {
+
+	repo, stub := newStubbed()
+	stub.err = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "failed", nil)
+
+	w := newWidget("a")
+	w.Version = 3
+
+	if err := repo.Put(context.Background(), w, IfVersion); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("put = %v, want ErrConditionFailed", err)
+	}
+	if w.Version != 3 {
+		t.Errorf("failed put left version %d, want 3", w.Version)
+	}
+
+	if err := repo.Delete(context.Background(), w, IfVersion); !errors.Is(err, ErrConditionFailed) {
+		t.Errorf("delete = %v, want ErrConditionFailed", err)
+	}
+
+	stub.err = errors.New("throttled")
+	if err := repo.Put(context.Background(), w, IfVersion); err == nil || errors.Is(err, ErrConditionFailed) {
+		t.Errorf("put = %v, want the client error", err)
+	}
+}
+
+func TestDynamoDBQuery(t *testing.T) {
+
+	repo, stub := newStubbed()
+
+	last := map[string]*dynamodb.AttributeValue{
+		"PK":     {S: aws.String("WIDGET#b")},
+		"SK":     {S: aws.String("WIDGET")},
+		"GSI1PK": {S: aws.String("WIDGETS")},
+		"GSI1SK": {S: aws.String("b")},
+	}
+	stub.page = dynamodb.QueryOutput{
+		Items: []map[string]*dynamodb.AttributeValue{
+			{"PK": {S: aws.String("WIDGET#a")}, "SK": {S: aws.String("WIDGET")}, "Name": {S: aws.String("a")}},
+			{"PK": {S: aws.String("WIDGET#b")}, "SK": {S: aws.String("WIDGET")}, "Name": {S: aws.String("b")}},
+		},
+		LastEvaluatedKey: last,
+	}
+
+	var page []widget
+	p, err := repo.Query(context.Background(), Query{Index: "GSI1", PK: "WIDGETS", SKPrefix: "w", Limit: 2, Descending: true}, &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := stub.query
+	if got := resolve(in.KeyConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues); got != `(GSI1PK = "WIDGETS") AND (begins_with (GSI1SK, "w"))` {
+		t.Errorf("key condition %s", got)
+	}
+	if aws.StringValue(in.IndexName) != "GSI1" || aws.Int64Value(in.Limit) != 2 || aws.BoolValue(in.ScanIndexForward) || in.ExclusiveStartKey != nil {
+		t.Errorf("query input %v", in)
+	}
+	if !strings.Contains(resolve(in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues), "attribute_not_exists (ttl)") {
+		t.Errorf("expired items not filtered: %s", aws.StringValue(in.FilterExpression))
+	}
+
+	if len(page) != 2 || page[0].Name != "a" || p.Next == "" {
+		t.Fatalf("page %+v, cursor %q", page, p.Next)
+	}
+
+	// the cursor resumes at the last evaluated key
+	stub.page = dynamodb.QueryOutput{}
+	if p, err = repo.Query(context.Background(), Query{Index: "GSI1", PK: "WIDGETS", Cursor: p.Next}, &page); err != nil {
+		t.Fatal(err)
+	}
+	if start := stub.query.ExclusiveStartKey; len(start) != 4 || aws.StringValue(start["GSI1SK"].S) != "b" || aws.StringValue(start["PK"].S) != "WIDGET#b" {
+		t.Errorf("exclusive start key %v", start)
+	}
+	if p.Next != "" || stub.query.IndexName == nil || stub.query.Limit != nil {
+		t.Errorf("last page cursor %q, input %v", p.Next, stub.query)
+	}
+
+	if _, err := repo.Query(context.Background(), Query{PK: "WIDGETS", Cursor: "bm90LWpzb24"}, &page); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestDynamoDBNoTable(t *testing.T) {
+
+	repo := NewDynamoDB(&stubDynamoDB{}, func(context.Context) string { return "" })
+
+	if err := repo.Put(context.Background(), newWidget("a"), Unconditional); !errors.Is(err, ErrNoTable) {
+		t.Errorf("put = %v, want ErrNoTable", err)
+	}
+	if _, err := repo.Query(context.Background(), Query{PK: "WIDGETS"}, &[]widget{}); !errors.Is(err, ErrNoTable) {
+		t.Errorf("query = %v, want ErrNoTable", err)
+	}
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+var _ Repository = (*Memory)(nil)
+
+// Memory is an in-memory Repository for offline tests, it stores items as
+// DynamoDB attribute maps so marshalling behaves as it does against a table
+type Memory struct {
+	mu    sync.Mutex
+	items map[Key]map[string]*dynamodb.AttributeValue
+
+	// Now is the clock used for TTL expiry
+	Now func() time.Time
+}
+
+// NewMemory returns an empty in-memory Repository
+func NewMemory() *Memory {
+	return &Memory{
+		items: map[Key]map[string]*dynamodb.AttributeValue{},
+		Now:   time.Now,
+	}
+}
+
+// Len returns the number of stored items, expired or not
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.items)
+}
+
+// lookup returns the live item at key, callers hold mu
+func (m *Memory) lookup(key Key) (map[string]*dynamodb.AttributeValue, bool) {
+	av, ok := m.items[key]
+	if !ok || expired(av, m.Now()) {
+		return nil, false
+	}
+	return av, true
+}
+
+// check applies a write condition, callers hold mu
+func (m *Memory) check(key Key, cond Condition, version int64) error {
+
+	current, exists := m.lookup(key)
+
+	switch cond {
+	case IfNotExists:
+		if exists {
+			return ErrConditionFailed
+		}
+	case IfExists:
+		if !exists {
+			return ErrConditionFailed
+		}
+	case IfVersion:
+		if !exists || numberAttr(current, "Version") != version {
+			return ErrConditionFailed
+		}
+	}
+
+	return nil
+}
+
+// Get reads the item at key into out
+func (m *Memory) Get(ctx context.Context, key Key, out Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	av, ok := m.lookup(key)
+	if !ok {
+		return ErrNotFound
+	}
+
+	return dynamodbattribute.UnmarshalMap(av, out)
+}
+
+// Put writes rec, incrementing its Version on success
+func (m *Memory) Put(ctx context.Context, rec Record, cond Condition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it := rec.item()
+	key := rec.Key()
+
+	if err := m.check(key, cond, it.Version); err != nil {
+		return err
+	}
+
+	it.Version++
+
+	av, err := dynamodbattribute.MarshalMap(rec)
+	if err != nil {
+		it.Version--
+		return err
+	}
+
+	m.items[key] = av
+
+	return nil
+}
+
+// Delete removes rec, IfVersion uses the rec Version
+func (m *Memory) Delete(ctx context.Context, rec Record, cond Condition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := rec.Key()
+
+	if err := m.check(key, cond, rec.item().Version); err != nil {
+		return err
+	}
+
+	delete(m.items, key)
+
+	return nil
+}
+
+// Query reads a page of items into out, a pointer to a slice. As DynamoDB
+// it applies Limit before dropping expired items, and returns a cursor
+// whenever the page reached Limit, even when no items remain.
+func (m *Memory) Query(ctx context.Context, q Query, out interface{}) (Page, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	pkName, skName := indexKeys(q.Index)
+
+	var matched []map[string]*dynamodb.AttributeValue
+
+	for _, av := range m.items {
+
+		if stringAttr(av, pkName) != q.PK {
+			continue
+		}
+
+		sk, ok := av[skName]
+		if !ok || sk.S == nil || !strings.HasPrefix(*sk.S, q.SKPrefix) {
+			continue
+		}
+
+		matched = append(matched, av)
+	}
+
+	// sort key order, primary key breaks ties on an index
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := sortKey(matched[i], skName), sortKey(matched[j], skName)
+		if q.Descending {
+			return a > b
+		}
+		return a < b
+	})
+
+	// resume after the cursor
+	if start != nil {
+		after := sortKey(start, skName)
+
+		var rest []map[string]*dynamodb.AttributeValue
+
+		for _, av := range matched {
+			k := sortKey(av, skName)
+			if (!q.Descending && k > after) || (q.Descending && k < after) {
+				rest = append(rest, av)
+			}
+		}
+
+		matched = rest
+	}
+
+	var last map[string]*dynamodb.AttributeValue
+
+	if q.Limit > 0 && int64(len(matched)) >= q.Limit {
+		matched = matched[:q.Limit]
+		last = lastKey(matched[len(matched)-1], pkName, skName)
+	}
+
+	// the filter expression, after Limit
+	live := matched[:0:0]
+	for _, av := range matched {
+		if !expired(av, m.Now()) {
+			live = append(live, av)
+		}
+	}
+
+	if err := dynamodbattribute.UnmarshalListOfMaps(live, out); err != nil {
+		return Page{}, err
+	}
+
+	next, err := encodeCursor(last)
+
+	return Page{Next: next}, err
+}
+
+// lastKey is the LastEvaluatedKey DynamoDB would return for av
+func lastKey(av map[string]*dynamodb.AttributeValue, pkName, skName string) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{
+		"PK": av["PK"],
+		"SK": av["SK"],
+	}
+	key[pkName] = av[pkName]
+	key[skName] = av[skName]
+	return key
+}
+
+// sortKey orders by the index sort key, then the primary key
+func sortKey(av map[string]*dynamodb.AttributeValue, skName string) string {
+	return stringAttr(av, skName) + "\x00" + stringAttr(av, "PK") + "\x00" + stringAttr(av, "SK")
+}
+
+func numberAttr(av map[string]*dynamodb.AttributeValue, name string) int64 {
+	if v, ok := av[name]; ok && v.N != nil {
+		n, _ := strconv.ParseInt(*v.N, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func stringAttr(av map[string]*dynamodb.AttributeValue, name string) string {
+	if v, ok := av[name]; ok && v.S != nil {
+		return *v.S
+	}
+	return ""
+}
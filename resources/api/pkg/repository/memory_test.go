@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type widget struct {
+	Item
+	Name string `dynamodbav:"Name"`
+}
+
+func newWidget(id string) *widget {
+	return &widget{
+		Item: Item{PK: "WIDGET#" + id, SK: "WIDGET", GSI1PK: "WIDGETS", GSI1SK: id, Type: "widget"},
+		Name: id,
+	}
+}
+
+func TestMemoryConditionalWrites(t *testing.T) {
+
+	ctx := context.Background()
+	repo := NewMemory()
+
+	w := newWidget("a")
+
+	if err := repo.Put(ctx, w, IfNotExists); err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if w.Version != 1 {
+		t.Fatalf("version after create = %d, want 1", w.Version)
+	}
+
+	if err := repo.Put(ctx, newWidget("a"), IfNotExists); !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("second create = %v, want ErrConditionFailed", err)
+	}
+
+	stale := newWidget("a")
+	stale.Version = 0
+	if err := repo.Put(ctx, stale, IfVersion); !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("stale update = %v, want ErrConditionFailed", err)
+	}
+	if stale.Version != 0 {
+		t.Fatalf("failed put changed the version to %d", stale.Version)
+	}
+
+	w.Name = "renamed"
+	if err := repo.Put(ctx, w, IfVersion); err != nil {
+		t.Fatalf("update: %s", err)
+	}
+
+	got := &widget{}
+	if err := repo.Get(ctx, w.Key(), got); err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if got.Name != "renamed" || got.Version != 2 {
+		t.Fatalf("got %+v", got)
+	}
+
+	if err := repo.Delete(ctx, newWidget("missing"), IfExists); !errors.Is(err, ErrConditionFailed) {
+		t.Fatalf("delete missing = %v, want ErrConditionFailed", err)
+	}
+
+	if err := repo.Delete(ctx, got, IfVersion); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+
+	if err := repo.Get(ctx, w.Key(), &widget{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+
+	ctx := context.Background()
+	repo := NewMemory()
+
+	now := time.Unix(1000, 0)
+	repo.Now = func() time.Time { return now }
+
+	w := newWidget("a")
+	w.ExpiresAt = 1010
+
+	if err := repo.Put(ctx, w, Unconditional); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+
+	if err := repo.Get(ctx, w.Key(), &widget{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get expired = %v, want ErrNotFound", err)
+	}
+
+	// expired but not yet removed counts as absent
+	if err := repo.Put(ctx, newWidget("a"), IfNotExists); err != nil {
+		t.Fatalf("create over expired: %s", err)
+	}
+}
+
+func TestMemoryQueryPagination(t *testing.T) {
+
+	ctx := context.Background()
+	repo := NewMemory()
+
+	for i := 0; i < 5; i++ {
+		if err := repo.Put(ctx, newWidget(fmt.Sprintf("w%d", i)), Unconditional); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	cursor := ""
+
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+
+		var page []widget
+
+		p, err := repo.Query(ctx, Query{Index: "GSI1", PK: "WIDGETS", Limit: 2, Cursor: cursor}, &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, w := range page {
+			names = append(names, w.Name)
+		}
+
+		if p.Next == "" {
+			break
+		}
+		cursor = p.Next
+	}
+
+	if fmt.Sprint(names) != "[w0 w1 w2 w3 w4]" {
+		t.Fatalf("names = %v", names)
+	}
+
+	// as DynamoDB, a full page has a cursor even when nothing follows
+	var full []widget
+	p, err := repo.Query(ctx, Query{Index: "GSI1", PK: "WIDGETS", Limit: 5}, &full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) != 5 || p.Next == "" {
+		t.Fatalf("full page has %d items and cursor %q, want 5 and a cursor", len(full), p.Next)
+	}
+
+	var empty []widget
+	p, err = repo.Query(ctx, Query{Index: "GSI1", PK: "WIDGETS", Limit: 5, Cursor: p.Next}, &empty)
+	if err != nil || len(empty) != 0 || p.Next != "" {
+		t.Fatalf("after the full page got %d items, cursor %q, err %v, want an empty last page", len(empty), p.Next, err)
+	}
+
+	// expired items count towards Limit, the page is short
+	now := time.Now()
+	repo.Now = func() time.Time { return now }
+	expiring := newWidget("w0")
+	expiring.ExpiresAt = now.Add(-time.Minute).Unix()
+	if err := repo.Put(ctx, expiring, Unconditional); err != nil {
+		t.Fatal(err)
+	}
+
+	var short []widget
+	p, err = repo.Query(ctx, Query{Index: "GSI1", PK: "WIDGETS", Limit: 2}, &short)
+	if err != nil || len(short) != 1 || short[0].Name != "w1" || p.Next == "" {
+		t.Fatalf("page over an expired item = %+v, cursor %q, err %v, want w1 and a cursor", short, p.Next, err)
+	}
+
+	var desc []widget
+	if _, err := repo.Query(ctx, Query{Index: "GSI1", PK: "WIDGETS", SKPrefix: "w", Descending: true, Limit: 1}, &desc); err != nil {
+		t.Fatal(err)
+	}
+	if len(desc) != 1 || desc[0].Name != "w4" {
+		t.Fatalf("descending = %+v", desc)
+	}
+
+	if _, err := repo.Query(ctx, Query{PK: "WIDGETS", Cursor: "not-a-cursor"}, &desc); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("bad cursor = %v, want ErrInvalidCursor", err)
+	}
+}
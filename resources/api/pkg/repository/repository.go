@@ -0,0 +1,157 @@
+// Package repository is a single-table data layer, entities embed Item and
+// are stored under a PK/SK pair with optional GSI keys, optimistic locking
+// via Version and expiry via the table TTL attribute
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrNotFound is returned when no live item exists for a key
+	ErrNotFound = errors.New("item not found")
+
+	// ErrConditionFailed is returned when a conditional write is rejected
+	ErrConditionFailed = errors.New("condition failed")
+
+	// ErrNoTable is returned when no table is configured
+	ErrNoTable = errors.New("no table configured")
+
+	// ErrInvalidCursor is returned for a cursor this package didn't issue
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// TTLAttribute is the table time to live attribute
+const TTLAttribute = "ttl"
+
+// Key is the primary key of an item
+type Key struct {
+	PK string
+	SK string
+}
+
+// Item carries the table keys and bookkeeping, embed it in an entity
+type Item struct {
+	PK     string `dynamodbav:"PK" json:"-"`
+	SK     string `dynamodbav:"SK" json:"-"`
+	GSI1PK string `dynamodbav:"GSI1PK,omitempty" json:"-"`
+	GSI1SK string `dynamodbav:"GSI1SK,omitempty" json:"-"`
+	// entity type, for mixed item collections
+	Type string `dynamodbav:"Type" json:"-"`
+	// incremented by every successful Put
+	Version int64 `dynamodbav:"Version" json:"version"`
+	// unix seconds, 0 never expires
+	ExpiresAt int64 `dynamodbav:"ttl,omitempty" json:"-"`
+}
+
+// Key returns the primary key of the item
+func (i *Item) Key() Key {
+	return Key{PK: i.PK, SK: i.SK}
+}
+
+func (i *Item) item() *Item {
+	return i
+}
+
+// Record is any entity embedding Item
+type Record interface {
+	Key() Key
+	item() *Item
+}
+
+// Condition guards a write
+type Condition int
+
+const (
+	// Unconditional writes always succeed, last writer wins
+	Unconditional Condition = iota
+	// IfNotExists only creates
+	IfNotExists
+	// IfExists only replaces or deletes an existing item
+	IfExists
+	// IfVersion requires the stored Version to match the record's
+	IfVersion
+)
+
+// Query selects an item collection, in sort key order
+type Query struct {
+	// "" for the table, otherwise a GSI name e.g. GSI1
+	Index string
+	// partition key value
+	PK string
+	// optional sort key prefix
+	SKPrefix string
+	// page size, 0 leaves it to the store
+	Limit int64
+	// Page.Next from the previous query
+	Cursor string
+	// descending sort key order
+	Descending bool
+}
+
+// Page describes a page of results
+type Page struct {
+	// cursor for the next page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// Repository is implemented by DynamoDB and the in-memory fake
+type Repository interface {
+	// Get reads the item at key into out
+	Get(ctx context.Context, key Key, out Record) error
+	// Put writes rec, incrementing its Version on success
+	Put(ctx context.Context, rec Record, cond Condition) error
+	// Delete removes rec, IfVersion uses the rec Version
+	Delete(ctx context.Context, rec Record, cond Condition) error
+	// Query reads a page of items into out, a pointer to a slice
+	Query(ctx context.Context, q Query, out interface{}) (Page, error)
+}
+
+// indexKeys returns the key attribute names of an index
+func indexKeys(index string) (string, string) {
+	if index == "" {
+		return "PK", "SK"
+	}
+	return index + "PK", index + "SK"
+}
+
+// encodeCursor serialises a last evaluated key
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var key map[string]*dynamodb.AttributeValue
+
+	if err := json.Unmarshal(raw, &key); err != nil || len(key) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return key, nil
+}
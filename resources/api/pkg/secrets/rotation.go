@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// Rotation steps, Secrets Manager invokes the rotation function once per
+// step with the same ClientRequestToken, the new version's ID
+const (
+	StepCreate = "createSecret"
+	StepSet    = "setSecret"
+	StepTest   = "testSecret"
+	StepFinish = "finishSecret"
+)
+
+// RotationEvent is the request Secrets Manager sends a rotation function
+type RotationEvent struct {
+	SecretID           string `json:"SecretId"`
+	ClientRequestToken string `json:"ClientRequestToken"`
+	Step               string `json:"Step"`
+}
+
+// Rotator rotates the generated secrets the hosting stack creates. Their
+// value is a random password only the application reads, so there is no
+// service to set it in: a new value is labelled AWSPENDING, then made
+// AWSCURRENT, and readers keep the AWSPREVIOUS version valid meanwhile.
+type Rotator struct {
+	client secretsmanageriface.SecretsManagerAPI
+}
+
+// NewRotator returns a Rotator
+func NewRotator(client secretsmanageriface.SecretsManagerAPI) *Rotator {
+	return &Rotator{client: client}
+}
+
+// Rotate runs a rotation step, steps may be retried
+func (r *Rotator) Rotate(ctx context.Context, event RotationEvent) error {
+
+	described, err := r.client.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(event.SecretID),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !aws.BoolValue(described.RotationEnabled) {
+		return fmt.Errorf("secret %s is not enabled for rotation", event.SecretID)
+	}
+
+	stages, ok := described.VersionIdsToStages[event.ClientRequestToken]
+	if !ok {
+		return fmt.Errorf("secret %s has no version %s", event.SecretID, event.ClientRequestToken)
+	}
+	if hasStage(stages, StageCurrent) {
+		// the rotation already finished
+		return nil
+	}
+	if !hasStage(stages, StagePending) {
+		return fmt.Errorf("version %s of secret %s is not %s", event.ClientRequestToken, event.SecretID, StagePending)
+	}
+
+	switch event.Step {
+	case StepCreate:
+		return r.create(ctx, event)
+	case StepSet:
+		// only the application reads the value
+		return nil
+	case StepTest:
+		_, err := r.pending(ctx, event)
+		return err
+	case StepFinish:
+		return r.finish(ctx, event, described.VersionIdsToStages)
+	}
+
+	return fmt.Errorf("unknown rotation step %q", event.Step)
+}
+
+// create puts a new random value as the pending version, unless a retried
+// step already did
+func (r *Rotator) create(ctx context.Context, event RotationEvent) error {
+
+	if _, err := r.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(event.SecretID),
+		VersionStage: aws.String(StageCurrent),
+	}); err != nil {
+		return err
+	}
+
+	_, err := r.pending(ctx, event)
+	if err == nil || !isNotFound(err) {
+		return err
+	}
+
+	// as generated on creation, see secrets.SecretsStack in the hosting stack
+	password, err := r.client.GetRandomPasswordWithContext(ctx, &secretsmanager.GetRandomPasswordInput{
+		ExcludePunctuation: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.PutSecretValueWithContext(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:           aws.String(event.SecretID),
+		ClientRequestToken: aws.String(event.ClientRequestToken),
+		SecretString:       password.RandomPassword,
+		VersionStages:      aws.StringSlice([]string{StagePending}),
+	})
+
+	return err
+}
+
+// pending reads the version being rotated to
+func (r *Rotator) pending(ctx context.Context, event RotationEvent) (*secretsmanager.GetSecretValueOutput, error) {
+	return r.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(event.SecretID),
+		VersionId:    aws.String(event.ClientRequestToken),
+		VersionStage: aws.String(StagePending),
+	})
+}
+
+// finish moves AWSCURRENT to the pending version, Secrets Manager labels
+// the replaced version AWSPREVIOUS
+func (r *Rotator) finish(ctx context.Context, event RotationEvent, versions map[string][]*string) error {
+
+	current := ""
+	for version, stages := range versions {
+		if hasStage(stages, StageCurrent) {
+			current = version
+		}
+	}
+
+	in := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:        aws.String(event.SecretID),
+		VersionStage:    aws.String(StageCurrent),
+		MoveToVersionId: aws.String(event.ClientRequestToken),
+	}
+	if current != "" {
+		in.RemoveFromVersionId = aws.String(current)
+	}
+
+	_, err := r.client.UpdateSecretVersionStageWithContext(ctx, in)
+
+	return err
+}
+
+func hasStage(stages []*string, stage string) bool {
+	for _, s := range stages {
+		if aws.StringValue(s) == stage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// fakeRotatingSecret is a single secret's versions, by ID
+type fakeRotatingSecret struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	values    map[string]string
+	stages    map[string][]string
+	passwords int
+}
+
+func (f *fakeRotatingSecret) DescribeSecretWithContext(ctx aws.Context, in *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+
+	versions := map[string][]*string{}
+	for version, stages := range f.stages {
+		versions[version] = aws.StringSlice(stages)
+	}
+
+	return &secretsmanager.DescribeSecretOutput{RotationEnabled: aws.Bool(true), VersionIdsToStages: versions}, nil
+}
+
+func (f *fakeRotatingSecret) GetSecretValueWithContext(ctx aws.Context, in *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+
+	for version, stages := range f.stages {
+		// the version Secrets Manager labels pending has no value yet
+		if _, ok := f.values[version]; !ok || in.VersionId != nil && *in.VersionId != version {
+			continue
+		}
+		for _, stage := range stages {
+			if stage == aws.StringValue(in.VersionStage) {
+				return &secretsmanager.GetSecretValueOutput{VersionId: aws.String(version), SecretString: aws.String(f.values[version])}, nil
+			}
+		}
+	}
+
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "no version", nil)
+}
+
+func (f *fakeRotatingSecret) GetRandomPasswordWithContext(ctx aws.Context, in *secretsmanager.GetRandomPasswordInput, opts ...request.Option) (*secretsmanager.GetRandomPasswordOutput, error) {
+	f.passwords++
+	return &secretsmanager.GetRandomPasswordOutput{RandomPassword: aws.String("generated")}, nil
+}
+
+func (f *fakeRotatingSecret) PutSecretValueWithContext(ctx aws.Context, in *secretsmanager.PutSecretValueInput, opts ...request.Option) (*secretsmanager.PutSecretValueOutput, error) {
+
+	version := aws.StringValue(in.ClientRequestToken)
+	f.values[version] = aws.StringValue(in.SecretString)
+	f.stages[version] = aws.StringValueSlice(in.VersionStages)
+
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeRotatingSecret) UpdateSecretVersionStageWithContext(ctx aws.Context, in *secretsmanager.UpdateSecretVersionStageInput, opts ...request.Option) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+
+	// Secrets Manager moves AWSPREVIOUS along with AWSCURRENT
+	removed := aws.StringValue(in.RemoveFromVersionId)
+	for version := range f.stages {
+		if version != removed {
+			f.stages[version] = withoutStage(f.stages[version], StagePrevious)
+		}
+	}
+	f.stages[removed] = []string{StagePrevious}
+	f.stages[aws.StringValue(in.MoveToVersionId)] = []string{StageCurrent}
+
+	return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+}
+
+func withoutStage(stages []string, stage string) []string {
+	kept := []string{}
+	for _, s := range stages {
+		if s != stage {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func TestRotate(t *testing.T) {
+
+	sm := &fakeRotatingSecret{
+		values: map[string]string{"v1": "initial"},
+		stages: map[string][]string{"v1": {StageCurrent}},
+	}
+	rotator := NewRotator(sm)
+
+	// Secrets Manager labels the new version before invoking the function
+	sm.stages["v2"] = []string{StagePending}
+
+	for _, step := range []string{StepCreate, StepCreate, StepSet, StepTest, StepFinish, StepFinish} {
+		if err := rotator.Rotate(context.Background(), RotationEvent{SecretID: "arn:secret", ClientRequestToken: "v2", Step: step}); err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+	}
+
+	if sm.values["v2"] != "generated" || sm.passwords != 1 {
+		t.Errorf("v2 is %q after %d passwords, want one generated", sm.values["v2"], sm.passwords)
+	}
+
+	for version, want := range map[string]string{"v1": StagePrevious, "v2": StageCurrent} {
+		if stages := sm.stages[version]; len(stages) != 1 || stages[0] != want {
+			t.Errorf("%s is %v, want %s", version, stages, want)
+		}
+	}
+
+	if err := rotator.Rotate(context.Background(), RotationEvent{SecretID: "arn:secret", ClientRequestToken: "v3", Step: StepCreate}); err == nil {
+		t.Error("rotating to an unknown version, want an error")
+	}
+}
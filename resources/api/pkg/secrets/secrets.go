@@ -0,0 +1,236 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// Version stages, a rotation labels the new version AWSPENDING until it is
+// tested, then moves AWSCURRENT to it and AWSPREVIOUS to the old version
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+	StagePending  = "AWSPENDING"
+)
+
+// DefaultTTL is how long a version is served before it is read again
+const DefaultTTL = 5 * time.Minute
+
+// EnvVar is the variable holding a secret's ARN e.g. SECRET_DB_ARN for db,
+// the hosting stack sets them through it
+func EnvVar(name string) string {
+	return "SECRET_" + strings.ToUpper(strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)) + "_ARN"
+}
+
+// ARN returns the ARN of a declared secret, empty when it is not declared
+func ARN(name string) string {
+	return os.Getenv(EnvVar(name))
+}
+
+// Declared returns the ARNs of the declared secrets
+func Declared() []string {
+
+	arns := []string{}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if strings.HasPrefix(parts[0], "SECRET_") && strings.HasSuffix(parts[0], "_ARN") && len(parts) == 2 && parts[1] != "" {
+			arns = append(arns, parts[1])
+		}
+	}
+
+	return arns
+}
+
+// Secret is a version of a secret
+type Secret struct {
+	ARN       string
+	VersionID string
+	Stages    []string
+	String    string
+	Binary    []byte
+}
+
+type entry struct {
+	secret  Secret
+	fetched time.Time
+}
+
+// call is a fetch in flight, callers of the same version wait for it
+type call struct {
+	done   chan struct{}
+	secret Secret
+	err    error
+}
+
+// Cache reads secrets through an in-memory cache, per version stage.
+// Rotations keep the previous version valid until the next one, so a
+// version is served for the TTL and, when Secrets Manager cannot be read,
+// beyond it. A caller whose credential is rejected once a rotation has
+// finished calls Invalidate and reads the new AWSCURRENT version.
+// Secrets Manager is read without holding the lock, once per version.
+type Cache struct {
+	client secretsmanageriface.SecretsManagerAPI
+	ttl    func(ctx context.Context) time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inflight map[string]*call
+
+	// Now is the clock entries expire by
+	Now func() time.Time
+}
+
+// New returns a Cache, ttl is resolved per call so it can come from request
+// scoped config, 0 uses DefaultTTL
+func New(client secretsmanageriface.SecretsManagerAPI, ttl func(ctx context.Context) time.Duration) *Cache {
+	return &Cache{
+		client:   client,
+		ttl:      ttl,
+		entries:  map[string]*entry{},
+		inflight: map[string]*call{},
+		Now:      time.Now,
+	}
+}
+
+func cacheKey(id, stage string) string {
+	return id + "\x00" + stage
+}
+
+// Get returns the AWSCURRENT version of the secret, by ARN or name
+func (c *Cache) Get(ctx context.Context, id string) (Secret, error) {
+	return c.GetStage(ctx, id, StageCurrent)
+}
+
+// GetStage returns the version of the secret labelled stage
+func (c *Cache) GetStage(ctx context.Context, id string, stage string) (Secret, error) {
+
+	key := cacheKey(id, stage)
+
+	c.mu.Lock()
+
+	cached, ok := c.entries[key]
+	if ok && c.Now().Sub(cached.fetched) < c.ttlFor(ctx) {
+		c.mu.Unlock()
+		return cached.secret, nil
+	}
+
+	if pending, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+
+		select {
+		case <-pending.done:
+			return pending.secret, pending.err
+		case <-ctx.Done():
+			return Secret{}, ctx.Err()
+		}
+	}
+
+	pending := &call{done: make(chan struct{})}
+	c.inflight[key] = pending
+
+	c.mu.Unlock()
+
+	secret, err := c.fetch(ctx, id, stage)
+
+	c.mu.Lock()
+	pending.secret, pending.err = c.store(id, stage, secret, err)
+	delete(c.inflight, key)
+	c.mu.Unlock()
+
+	close(pending.done)
+
+	return pending.secret, pending.err
+}
+
+// store caches a fetched version, falling back to the cached one when
+// Secrets Manager cannot be read. The caller holds the lock.
+func (c *Cache) store(id, stage string, secret Secret, err error) (Secret, error) {
+
+	now := c.Now()
+	key := cacheKey(id, stage)
+
+	cached, ok := c.entries[key]
+
+	switch {
+	case isNotFound(err):
+		// e.g. AWSPENDING once the rotation finished
+		delete(c.entries, key)
+		return Secret{}, err
+
+	case err != nil && ok:
+		// the cached version is still valid, retry on the next call
+		return cached.secret, nil
+
+	case err != nil:
+		return Secret{}, err
+	}
+
+	// a rotation finished, the replaced version is now AWSPREVIOUS
+	if ok && stage == StageCurrent && cached.secret.VersionID != secret.VersionID {
+		previous := cached.secret
+		previous.Stages = []string{StagePrevious}
+		c.entries[cacheKey(id, StagePrevious)] = &entry{secret: previous, fetched: now}
+	}
+
+	c.entries[key] = &entry{secret: secret, fetched: now}
+
+	return secret, nil
+}
+
+// Invalidate drops the cached versions of the secret
+func (c *Cache) Invalidate(id string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, stage := range []string{StageCurrent, StagePrevious, StagePending} {
+		delete(c.entries, cacheKey(id, stage))
+	}
+}
+
+func (c *Cache) ttlFor(ctx context.Context) time.Duration {
+	if c.ttl != nil {
+		if ttl := c.ttl(ctx); ttl > 0 {
+			return ttl
+		}
+	}
+	return DefaultTTL
+}
+
+func (c *Cache) fetch(ctx context.Context, id string, stage string) (Secret, error) {
+
+	out, err := c.client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(id),
+		VersionStage: aws.String(stage),
+	})
+	if err != nil {
+		return Secret{}, err
+	}
+
+	return Secret{
+		ARN:       aws.StringValue(out.ARN),
+		VersionID: aws.StringValue(out.VersionId),
+		Stages:    aws.StringValueSlice(out.VersionStages),
+		String:    aws.StringValue(out.SecretString),
+		Binary:    out.SecretBinary,
+	}, nil
+}
+
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException
+}
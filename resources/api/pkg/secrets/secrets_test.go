@@ -0,0 +1,233 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// fakeSecretsManager serves the versions labelled with each stage
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	versions map[string]string // stage to version ID
+	err      error
+	calls    int
+
+	// started is sent to as a read begins, which then waits for block
+	started chan struct{}
+	block   chan struct{}
+}
+
+func (f *fakeSecretsManager) GetSecretValueWithContext(ctx aws.Context, in *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+
+	f.calls++
+
+	if f.started != nil {
+		f.started <- struct{}{}
+		<-f.block
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	stage := aws.StringValue(in.VersionStage)
+	version, ok := f.versions[stage]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "no version "+stage, nil)
+	}
+
+	return &secretsmanager.GetSecretValueOutput{
+		ARN:           in.SecretId,
+		VersionId:     aws.String(version),
+		VersionStages: aws.StringSlice([]string{stage}),
+		SecretString:  aws.String("value-" + version),
+	}, nil
+}
+
+func newTestCache(sm *fakeSecretsManager) (*Cache, *time.Time) {
+
+	now := time.Unix(0, 0)
+
+	cache := New(sm, func(context.Context) time.Duration { return time.Minute })
+	cache.Now = func() time.Time { return now }
+
+	return cache, &now
+}
+
+func get(t *testing.T, cache *Cache, stage string) string {
+	t.Helper()
+
+	secret, err := cache.GetStage(context.Background(), "arn:secret", stage)
+	if err != nil {
+		t.Fatalf("%s: %s", stage, err)
+	}
+
+	return secret.String
+}
+
+func TestCacheTTL(t *testing.T) {
+
+	sm := &fakeSecretsManager{versions: map[string]string{StageCurrent: "v1"}}
+	cache, now := newTestCache(sm)
+
+	get(t, cache, StageCurrent)
+	get(t, cache, StageCurrent)
+
+	if sm.calls != 1 {
+		t.Errorf("%d reads within the TTL, want 1", sm.calls)
+	}
+
+	*now = now.Add(time.Minute)
+
+	if got := get(t, cache, StageCurrent); got != "value-v1" || sm.calls != 2 {
+		t.Errorf("after the TTL got %s with %d reads, want value-v1 with 2", got, sm.calls)
+	}
+}
+
+func TestCacheRotation(t *testing.T) {
+
+	sm := &fakeSecretsManager{versions: map[string]string{StageCurrent: "v1"}}
+	cache, now := newTestCache(sm)
+
+	get(t, cache, StageCurrent)
+
+	// the rotation tests the pending version, then finishes
+	sm.versions = map[string]string{StageCurrent: "v1", StagePending: "v2"}
+	if got := get(t, cache, StagePending); got != "value-v2" {
+		t.Errorf("pending is %s, want value-v2", got)
+	}
+
+	sm.versions = map[string]string{StageCurrent: "v2", StagePrevious: "v1"}
+
+	// the old version is served until the TTL, it remains valid
+	if got := get(t, cache, StageCurrent); got != "value-v1" {
+		t.Errorf("current within the TTL is %s, want value-v1", got)
+	}
+
+	*now = now.Add(time.Minute)
+
+	if got := get(t, cache, StageCurrent); got != "value-v2" {
+		t.Errorf("current after the TTL is %s, want value-v2", got)
+	}
+
+	calls := sm.calls
+	if got := get(t, cache, StagePrevious); got != "value-v1" || sm.calls != calls {
+		t.Errorf("previous is %s after %d reads, want the replaced value-v1 without a read", got, sm.calls-calls)
+	}
+
+	// the pending label is gone
+	if _, err := cache.GetStage(context.Background(), "arn:secret", StagePending); !isNotFound(err) {
+		t.Errorf("pending after the rotation: %v, want not found", err)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+
+	sm := &fakeSecretsManager{versions: map[string]string{StageCurrent: "v1"}}
+	cache, _ := newTestCache(sm)
+
+	get(t, cache, StageCurrent)
+
+	sm.versions = map[string]string{StageCurrent: "v2"}
+	cache.Invalidate("arn:secret")
+
+	if got := get(t, cache, StageCurrent); got != "value-v2" {
+		t.Errorf("after Invalidate got %s, want value-v2", got)
+	}
+}
+
+func TestCacheServesStale(t *testing.T) {
+
+	sm := &fakeSecretsManager{versions: map[string]string{StageCurrent: "v1"}}
+	cache, now := newTestCache(sm)
+
+	get(t, cache, StageCurrent)
+
+	sm.err = errors.New("throttled")
+	*now = now.Add(time.Hour)
+
+	if got := get(t, cache, StageCurrent); got != "value-v1" {
+		t.Errorf("with Secrets Manager failing got %s, want the cached value-v1", got)
+	}
+
+	cache.Invalidate("arn:secret")
+
+	if _, err := cache.Get(context.Background(), "arn:secret"); err == nil {
+		t.Error("nothing cached and Secrets Manager failing, want an error")
+	}
+}
+
+func TestCacheConcurrentReads(t *testing.T) {
+
+	sm := &fakeSecretsManager{
+		versions: map[string]string{StageCurrent: "v1"},
+		started:  make(chan struct{}, 3),
+		block:    make(chan struct{}),
+	}
+	cache, _ := newTestCache(sm)
+
+	results := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			secret, _ := cache.Get(context.Background(), "arn:secret")
+			results <- secret.String
+		}()
+	}
+
+	<-sm.started
+
+	// the cache is not locked while Secrets Manager is read
+	invalidated := make(chan struct{})
+	go func() {
+		cache.Invalidate("arn:other")
+		close(invalidated)
+	}()
+	select {
+	case <-invalidated:
+	case <-time.After(time.Second):
+		t.Fatal("Invalidate waits for a read of another secret")
+	}
+
+	close(sm.block)
+
+	for i := 0; i < 3; i++ {
+		if got := <-results; got != "value-v1" {
+			t.Errorf("got %q, want value-v1", got)
+		}
+	}
+
+	if sm.calls != 1 {
+		t.Errorf("%d reads of the same version, want 1", sm.calls)
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+
+	t.Setenv("SECRET_GITHUB_TOKEN_ARN", "arn:github")
+
+	if got := ARN("github-token"); got != "arn:github" {
+		t.Errorf("ARN(github-token) = %q, want arn:github", got)
+	}
+
+	if declared := Declared(); len(declared) == 0 || !contains(declared, "arn:github") {
+		t.Errorf("declared %v, want arn:github", declared)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentTypeInToto is the in-toto attestation media type
+const ContentTypeInToto = "application/vnd.in-toto+json"
+
+const (
+	statementType  = "https://in-toto.io/Statement/v0.1"
+	predicateType  = "https://slsa.dev/provenance/v0.2"
+	buildType      = "https://golang.org/cmd/go/build@v1"
+	defaultBuilder = "urn:builder:local"
+	codebuildID    = "https://codebuild.amazonaws.com/codepipeline"
+)
+
+// Statement is an in-toto statement carrying a SLSA provenance predicate
+type Statement struct {
+	Type          string     `json:"_type"`
+	Subject       []Subject  `json:"subject"`
+	PredicateType string     `json:"predicateType"`
+	Predicate     Provenance `json:"predicate"`
+}
+
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type Provenance struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Metadata   Metadata   `json:"metadata"`
+	Materials  []Material `json:"materials"`
+}
+
+type Builder struct {
+	ID string `json:"id"`
+}
+
+type Invocation struct {
+	ConfigSource ConfigSource      `json:"configSource"`
+	Parameters   map[string]string `json:"parameters,omitempty"`
+	Environment  map[string]string `json:"environment,omitempty"`
+}
+
+type ConfigSource struct {
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type Metadata struct {
+	BuildInvocationID string       `json:"buildInvocationId,omitempty"`
+	BuildFinishedOn   string       `json:"buildFinishedOn,omitempty"`
+	Completeness      Completeness `json:"completeness"`
+	Reproducible      bool         `json:"reproducible"`
+}
+
+type Completeness struct {
+	Parameters  bool `json:"parameters"`
+	Environment bool `json:"environment"`
+	Materials   bool `json:"materials"`
+}
+
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// digest of the running binary, computed once
+var (
+	subjectOnce sync.Once
+	subject     Subject
+)
+
+func binarySubject() Subject {
+
+	subjectOnce.Do(func() {
+
+		subject = Subject{Name: "unknown", Digest: map[string]string{}}
+
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+
+		subject.Name = filepath.Base(path)
+
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return
+		}
+
+		subject.Digest["sha256"] = hex.EncodeToString(h.Sum(nil))
+	})
+
+	return subject
+}
+
+// NewStatement builds the provenance statement for the running binary
+func NewStatement() Statement {
+
+	builder := defaultBuilder
+	if PipelineExecutionID != "" {
+		builder = codebuildID
+	}
+
+	source := ConfigSource{
+		Digest: map[string]string{"sha1": BuildHash},
+	}
+	if SourceRepo != "" {
+		source.URI = "git+" + SourceRepo
+	}
+
+	var materials []Material
+
+	if SourceRepo != "" {
+		materials = append(materials, Material{
+			URI:    "git+" + SourceRepo,
+			Digest: map[string]string{"sha1": BuildHash},
+		})
+	}
+
+	for _, m := range Modules() {
+
+		material := Material{
+			URI: "pkg:golang/" + m.Path + "@" + m.Version,
+		}
+
+		// go.sum h1: hashes are base64 sha256 of the module tree
+		if strings.HasPrefix(m.Sum, "h1:") {
+			material.Digest = map[string]string{"h1": strings.TrimPrefix(m.Sum, "h1:")}
+		}
+
+		materials = append(materials, material)
+	}
+
+	return Statement{
+		Type:          statementType,
+		Subject:       []Subject{binarySubject()},
+		PredicateType: predicateType,
+		Predicate: Provenance{
+			Builder:   Builder{ID: builder},
+			BuildType: buildType,
+			Invocation: Invocation{
+				ConfigSource: source,
+				Parameters: map[string]string{
+					"version":     Version,
+					"tenant":      Tenant,
+					"environment": Environment,
+				},
+				Environment: map[string]string{
+					"goversion": runtime.Version(),
+					"dirty":     VCSDirty,
+				},
+			},
+			Metadata: Metadata{
+				BuildInvocationID: PipelineExecutionID,
+				BuildFinishedOn:   buildFinishedOn(),
+				Completeness: Completeness{
+					Parameters: true,
+					Materials:  true,
+				},
+			},
+			Materials: materials,
+		},
+	}
+}
+
+// buildFinishedOn converts BuildDate to RFC 3339
+func buildFinishedOn() string {
+
+	t, err := time.Parse("20060102", BuildDate)
+	if err != nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ProvenanceHandler returns the provenance statement
+func ProvenanceHandler(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", ContentTypeInToto)
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(NewStatement())
+}
@@ -0,0 +1,89 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewStatement(t *testing.T) {
+
+	build(t)
+
+	statement := NewStatement()
+
+	// the subject is the running binary, the test binary here
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(binary)
+
+	if len(statement.Subject) != 1 {
+		t.Fatalf("%d subjects, want 1", len(statement.Subject))
+	}
+	if subject := statement.Subject[0]; subject.Name != filepath.Base(path) || subject.Digest["sha256"] != hex.EncodeToString(digest[:]) {
+		t.Errorf("subject is %+v", subject)
+	}
+
+	predicate := statement.Predicate
+	if predicate.Builder.ID != defaultBuilder || predicate.BuildType != buildType {
+		t.Errorf("built by %s as %s", predicate.Builder.ID, predicate.BuildType)
+	}
+	if got := predicate.Metadata.BuildFinishedOn; got != "2021-10-15T00:00:00Z" {
+		t.Errorf("buildFinishedOn is %s", got)
+	}
+	if parameters := predicate.Invocation.Parameters; parameters["version"] != "1.0.42" || parameters["tenant"] != "acme" {
+		t.Errorf("parameters are %v", parameters)
+	}
+
+	// no source repository, only the modules
+	for _, material := range predicate.Materials {
+		if !strings.HasPrefix(material.URI, "pkg:golang/") {
+			t.Errorf("material %s", material.URI)
+		}
+		if digest, ok := material.Digest["h1"]; ok && strings.HasPrefix(digest, "h1:") {
+			t.Errorf("%s: digest %s keeps its prefix", material.URI, digest)
+		}
+	}
+}
+
+func TestNewStatementPipeline(t *testing.T) {
+
+	build(t)
+	PipelineExecutionID = "0bd5c2d2-1c4d-4a8c-9b1c-3f1a2b3c4d5e"
+	SourceRepo = "https://git-codecommit.eu-west-1.amazonaws.com/v1/repos/api"
+
+	predicate := NewStatement().Predicate
+
+	if predicate.Builder.ID != codebuildID || predicate.Metadata.BuildInvocationID != PipelineExecutionID {
+		t.Errorf("built by %s in %s", predicate.Builder.ID, predicate.Metadata.BuildInvocationID)
+	}
+
+	// the source commit is the first material
+	want := "git+" + SourceRepo
+	if predicate.Invocation.ConfigSource.URI != want {
+		t.Errorf("configSource is %s, want %s", predicate.Invocation.ConfigSource.URI, want)
+	}
+	if len(predicate.Materials) == 0 || predicate.Materials[0].URI != want || predicate.Materials[0].Digest["sha1"] != "0123abc" {
+		t.Errorf("materials are %+v", predicate.Materials)
+	}
+}
+
+func TestBuildFinishedOn(t *testing.T) {
+
+	build(t)
+	BuildDate = "unknown"
+
+	if got := buildFinishedOn(); got != "" {
+		t.Errorf("buildFinishedOn is %q for an unparsable date", got)
+	}
+}
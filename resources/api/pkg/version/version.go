@@ -2,6 +2,9 @@ package version
 
 import (
 	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
 
 	"github.com/go-chi/render"
 )
@@ -15,9 +18,55 @@ var BuildHash = "AAAAAAAA"
 // date the program was built
 var BuildDate = "19760101"
 
-//
-//
-//
+// whether the checkout had uncommitted changes, true/false/unknown
+var VCSDirty = "unknown"
+
+// deployment the binary was built for
+var Tenant = ""
+var Environment = ""
+
+// CodePipeline execution which produced the binary
+var PipelineExecutionID = ""
+
+// source repository URL
+var SourceRepo = ""
+
+// Module is a dependency compiled into the binary
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// Modules returns the dependency list recorded by the go toolchain
+func Modules() []Module {
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	var modules []Module
+
+	for _, dep := range bi.Deps {
+
+		m := Module{
+			Path:    dep.Path,
+			Version: dep.Version,
+			Sum:     dep.Sum,
+		}
+
+		if dep.Replace != nil {
+			m.Replace = dep.Replace.Path + "@" + dep.Replace.Version
+			m.Sum = dep.Replace.Sum
+		}
+
+		modules = append(modules, m)
+	}
+
+	return modules
+}
 
 type versionResponse struct {
 	Version string `json:"version"`
@@ -25,20 +74,44 @@ type versionResponse struct {
 	BuildHash string `json:"buildhash"`
 
 	BuildDate string `json:"builddate"`
+
+	GoVersion string `json:"goversion"`
+
+	Dirty bool `json:"dirty"`
+
+	Tenant string `json:"tenant,omitempty"`
+
+	Environment string `json:"environment,omitempty"`
+
+	PipelineExecutionID string `json:"pipelineexecutionid,omitempty"`
+
+	Modules []Module `json:"modules,omitempty"`
 }
 
 func (u *versionResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-// Handler used to return buildversion
+// Handler used to return buildversion, or an in-toto provenance statement
+// when asked for with ?format=provenance or the in-toto media type
 func Handler(w http.ResponseWriter, r *http.Request) {
 
+	if r.URL.Query().Get("format") == "provenance" || strings.Contains(r.Header.Get("Accept"), ContentTypeInToto) {
+		ProvenanceHandler(w, r)
+		return
+	}
+
 	render.Status(r, http.StatusOK)
 
 	render.Render(w, r, &versionResponse{
-		Version:   Version,
-		BuildHash: BuildHash,
-		BuildDate: BuildDate,
+		Version:             Version,
+		BuildHash:           BuildHash,
+		BuildDate:           BuildDate,
+		GoVersion:           runtime.Version(),
+		Dirty:               VCSDirty == "true",
+		Tenant:              Tenant,
+		Environment:         Environment,
+		PipelineExecutionID: PipelineExecutionID,
+		Modules:             Modules(),
 	})
 }
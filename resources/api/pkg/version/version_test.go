@@ -0,0 +1,84 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// build sets the injected values for the duration of a test
+func build(t *testing.T) {
+	t.Helper()
+
+	saved := []string{Version, BuildHash, BuildDate, VCSDirty, Tenant, Environment, PipelineExecutionID, SourceRepo}
+	t.Cleanup(func() {
+		Version, BuildHash, BuildDate, VCSDirty, Tenant, Environment, PipelineExecutionID, SourceRepo =
+			saved[0], saved[1], saved[2], saved[3], saved[4], saved[5], saved[6], saved[7]
+	})
+
+	Version = "1.0.42"
+	BuildHash = "0123abc"
+	BuildDate = "20211015"
+	VCSDirty = "false"
+	Tenant = "acme"
+	Environment = "test"
+	PipelineExecutionID = ""
+	SourceRepo = ""
+}
+
+func TestHandler(t *testing.T) {
+
+	build(t)
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("Content-Type is %s", got)
+	}
+
+	var got versionResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "1.0.42" || got.BuildHash != "0123abc" || got.BuildDate != "20211015" ||
+		got.GoVersion != runtime.Version() || got.Dirty || got.Tenant != "acme" || got.Environment != "test" {
+		t.Errorf("version is %+v", got)
+	}
+}
+
+func TestHandlerProvenance(t *testing.T) {
+
+	build(t)
+
+	for name, r := range map[string]*http.Request{
+		"format":  httptest.NewRequest(http.MethodGet, "/version?format=provenance", nil),
+		"accept":  httptest.NewRequest(http.MethodGet, "/version", nil),
+		"another": httptest.NewRequest(http.MethodGet, "/version", nil),
+	} {
+		switch name {
+		case "accept":
+			r.Header.Set("Accept", ContentTypeInToto)
+		case "another":
+			r.Header.Set("Accept", "application/json, "+ContentTypeInToto)
+		}
+
+		w := httptest.NewRecorder()
+		Handler(w, r)
+
+		if got := w.Header().Get("Content-Type"); got != ContentTypeInToto {
+			t.Errorf("%s: Content-Type is %s", name, got)
+		}
+
+		var statement Statement
+		if err := json.NewDecoder(w.Body).Decode(&statement); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if statement.Type != statementType || statement.PredicateType != predicateType {
+			t.Errorf("%s: statement is %s of %s", name, statement.Type, statement.PredicateType)
+		}
+	}
+}